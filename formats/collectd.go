@@ -0,0 +1,56 @@
+package formats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+)
+
+//Severities of collectd notifications
+const (
+	CollectdSeverityOkay    = "okay"
+	CollectdSeverityWarning = "warning"
+	CollectdSeverityFailure = "failure"
+)
+
+var collectdIdentReplacer = strings.NewReplacer("/", "_", "\"", "_", " ", "_", "\t", "_", "\n", "_")
+var collectdStringReplacer = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\r", "", "\n", " ")
+
+// collectd does not know unknown severity, so it is reported as warning
+func buildCollectdSeverity(checkResult connector.CheckResult) string {
+	switch Severity(checkResult.Result.Status) {
+	case SeverityOkay:
+		return CollectdSeverityOkay
+	case SeverityWarning, SeverityUnknown:
+		return CollectdSeverityWarning
+	}
+	return CollectdSeverityFailure
+}
+
+//CreateCollectdCommands formats Sensu result as collectd exec plugin commands. PUTNOTIF command
+//reports the check state and PUTVAL commands report check duration and status. Interval is omitted
+//from PUTVAL commands when it is not positive.
+func CreateCollectdCommands(input connector.CheckResult, host string, plugin string, interval float64) []string {
+	instance := collectdIdentReplacer.Replace(input.Result.Name)
+	host = collectdIdentReplacer.Replace(host)
+	intervalOpt := ""
+	if interval > 0 {
+		intervalOpt = fmt.Sprintf(" interval=%s", strconv.FormatFloat(interval, 'f', -1, 64))
+	}
+
+	notif := fmt.Sprintf("PUTNOTIF severity=%s time=%d host=\"%s\" plugin=\"%s\" plugin_instance=\"%s\" type=\"check\" message=\"%s\"",
+		buildCollectdSeverity(input),
+		input.Result.Executed,
+		host,
+		plugin,
+		instance,
+		collectdStringReplacer.Replace(strings.TrimSpace(input.Result.Output)),
+	)
+	duration := fmt.Sprintf("PUTVAL \"%s/%s-%s/duration\"%s %d:%f",
+		host, plugin, instance, intervalOpt, input.Result.Executed, input.Result.Duration)
+	status := fmt.Sprintf("PUTVAL \"%s/%s-%s/gauge-status\"%s %d:%d",
+		host, plugin, instance, intervalOpt, input.Result.Executed, input.Result.Status)
+	return []string{notif, duration, status}
+}
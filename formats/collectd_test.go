@@ -0,0 +1,33 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+)
+
+func TestCreateCollectdCommandsInterval(t *testing.T) {
+	result := connector.CheckResult{Result: connector.Result{Name: "check-ntp", Executed: 1600000000, Duration: 0.25, Status: 1}}
+	tests := []struct {
+		interval float64
+		option   string
+	}{
+		{0, ""},
+		{10, " interval=10 "},
+		{0.5, " interval=0.5 "},
+	}
+	for _, test := range tests {
+		commands := CreateCollectdCommands(result, "node-0", "sensubility", test.interval)
+		if len(commands) != 3 {
+			t.Fatalf("expected 3 commands, got %v", commands)
+		}
+		for _, command := range commands[1:] {
+			if test.option == "" && strings.Contains(command, "interval=") {
+				t.Errorf("expected no interval, got %s", command)
+			} else if test.option != "" && !strings.Contains(command, test.option) {
+				t.Errorf("expected %q in %s", test.option, command)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package formats
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+//Sources of host identity which can be used in host_uuid_sources configuration option
+const (
+	HostUUIDSourceConfig    = "config"
+	HostUUIDSourceMachineID = "machine-id"
+	HostUUIDSourceDMI       = "dmi"
+)
+
+//Paths to host identity sources
+var (
+	MachineIDPath      = "/etc/machine-id"
+	DMIProductUUIDPath = "/sys/class/dmi/id/product_uuid"
+)
+
+const hostUUIDFile = "host-uuid"
+
+var (
+	machineIDRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	uuidRegexp      = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+)
+
+//global indentifiers
+var (
+	//DefaultHostUUID is random identifier generated on startup. It is used when none of the host identity
+	//sources provides an identifier and nothing has been persisted yet.
+	DefaultHostUUID = defaultHostUUID()
+	//HostUUID identifies the host in formatted results. It is resolved by LoadHostUUID on startup.
+	HostUUID = DefaultHostUUID
+)
+
+func readIdentityFile(filePath string) string {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func machineID() string {
+	id := strings.ToLower(readIdentityFile(MachineIDPath))
+	if !machineIDRegexp.MatchString(id) || strings.Trim(id, "0") == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s-%s-%s-%s", id[0:8], id[8:12], id[12:16], id[16:20], id[20:32])
+}
+
+func dmiProductUUID() string {
+	id := strings.ToLower(readIdentityFile(DMIProductUUIDPath))
+	if !uuidRegexp.MatchString(id) || strings.Trim(id, "0-") == "" {
+		return ""
+	}
+	return id
+}
+
+func randomUUID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	// RFC 4122 version 4 and variant bits
+	buf[6] = (buf[6] & 0x0f) | 0x40
+	buf[8] = (buf[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16]), nil
+}
+
+func defaultHostUUID() string {
+	id, err := randomUUID()
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+//LoadHostUUID resolves host identity from given sources in given order. First source providing
+//an identifier wins. The identifier persisted in stateDir is used when none of the sources
+//provides one and DefaultHostUUID is used when nothing has been persisted yet. The resolved
+//identifier is always persisted in stateDir. Returned identifier is usable even when error
+//is returned, unless it is empty.
+func LoadHostUUID(sources []string, configured string, stateDir string) (string, error) {
+	id := ""
+	for _, source := range sources {
+		switch strings.TrimSpace(source) {
+		case HostUUIDSourceConfig:
+			id = strings.TrimSpace(configured)
+		case HostUUIDSourceMachineID:
+			id = machineID()
+		case HostUUIDSourceDMI:
+			id = dmiProductUUID()
+		case "":
+			continue
+		default:
+			return "", fmt.Errorf("unknown host identity source: %s", source)
+		}
+		if id != "" {
+			break
+		}
+	}
+
+	persisted := path.Join(stateDir, hostUUIDFile)
+	stored := readIdentityFile(persisted)
+	if id == "" {
+		id = stored
+	}
+	if id == "" {
+		if id = DefaultHostUUID; id == "" {
+			return "", fmt.Errorf("Failed to generate host UUID")
+		}
+	}
+	if id == stored {
+		return id, nil
+	}
+
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return id, fmt.Errorf("Failed to create state directory: %s", err)
+	}
+	if err := ioutil.WriteFile(persisted, []byte(id+"\n"), 0600); err != nil {
+		return id, fmt.Errorf("Failed to persist host UUID: %s", err)
+	}
+	return id, nil
+}
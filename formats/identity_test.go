@@ -0,0 +1,84 @@
+package formats
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestLoadHostUUID(t *testing.T) {
+	defer func(machineID, dmi string) {
+		MachineIDPath = machineID
+		DMIProductUUIDPath = dmi
+	}(MachineIDPath, DMIProductUUIDPath)
+
+	const (
+		configured = "b3c5e6f2-9a41-4d7e-8c0b-5f2a1e3d4c6b"
+		machineID  = "0F4A6C2E8B1D4E3F9A7C5B2D1E0F3A4C"
+		fromMID    = "0f4a6c2e-8b1d-4e3f-9a7c-5b2d1e0f3a4c"
+		dmiUUID    = "4C4C4544-0042-3510-8052-B4C04F384E32"
+		fromDMI    = "4c4c4544-0042-3510-8052-b4c04f384e32"
+		persisted  = "9d8c7b6a-5f4e-4d3c-8b2a-1f0e9d8c7b6a"
+	)
+	tests := []struct {
+		name       string
+		sources    string
+		configured string
+		machineID  string
+		dmi        string
+		persisted  string
+		expected   string
+	}{
+		{"config", "config,machine-id,dmi", configured, machineID, dmiUUID, persisted, configured},
+		{"machine-id", "config,machine-id,dmi", "", machineID, dmiUUID, persisted, fromMID},
+		{"dmi", "config,machine-id,dmi", "", "", dmiUUID, persisted, fromDMI},
+		{"order", "dmi, machine-id,config", configured, machineID, dmiUUID, "", fromDMI},
+		{"invalid machine-id", "machine-id,dmi", "", "not-a-machine-id", dmiUUID, "", fromDMI},
+		{"empty machine-id", "machine-id,dmi", "", strings.Repeat("0", 32), dmiUUID, "", fromDMI},
+		{"empty dmi", "dmi", "", "", "00000000-0000-0000-0000-000000000000", persisted, persisted},
+		{"persisted", "config,machine-id,dmi", "", "", "", persisted, persisted},
+		{"disabled sources", "", configured, machineID, dmiUUID, persisted, persisted},
+		{"random", "config,machine-id,dmi", "", "", "", "", DefaultHostUUID},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			stateDir := path.Join(dir, "state")
+			MachineIDPath = path.Join(dir, "machine-id")
+			DMIProductUUIDPath = path.Join(dir, "product_uuid")
+			files := map[string]string{MachineIDPath: test.machineID, DMIProductUUIDPath: test.dmi}
+			if test.persisted != "" {
+				files[path.Join(stateDir, hostUUIDFile)] = test.persisted
+			}
+			for filePath, content := range files {
+				if content == "" {
+					continue
+				}
+				if err := os.MkdirAll(path.Dir(filePath), 0700); err != nil {
+					t.Fatalf("failed to create directory of %s: %s", filePath, err)
+				}
+				if err := ioutil.WriteFile(filePath, []byte(content+"\n"), 0600); err != nil {
+					t.Fatalf("failed to write %s: %s", filePath, err)
+				}
+			}
+
+			id, err := LoadHostUUID(strings.Split(test.sources, ","), test.configured, stateDir)
+			if err != nil {
+				t.Fatalf("failed to load host UUID: %s", err)
+			}
+			if id != test.expected {
+				t.Errorf("expected host UUID %s, got %s", test.expected, id)
+			}
+			stored, err := ioutil.ReadFile(path.Join(stateDir, hostUUIDFile))
+			if err != nil || strings.TrimSpace(string(stored)) != test.expected {
+				t.Errorf("expected host UUID %s to be persisted, got %q (%v)", test.expected, stored, err)
+			}
+		})
+	}
+
+	if _, err := LoadHostUUID([]string{"config", "hostname"}, "", t.TempDir()); err == nil {
+		t.Errorf("expected unknown source to be rejected")
+	}
+}
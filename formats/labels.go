@@ -0,0 +1,102 @@
+package formats
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/infrawatch/collectd-sensubility/sensu"
+)
+
+//global labels
+var (
+	//Labels are added to all formatted results. They are loaded by LoadLabels on startup.
+	Labels = map[string]string{}
+)
+
+// parsePairs parses list in format "<key>=<value>,<key>=<value>,..."
+func parsePairs(list string) (map[string]string, error) {
+	output := make(map[string]string)
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid item: %s", item)
+		}
+		output[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return output, nil
+}
+
+// readFileLabel returns content of given file or value of given key in case the file contains
+// shell-compatible variable assignments (eg. /etc/os-release)
+func readFileLabel(path string, key string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return strings.TrimSpace(string(content)), nil
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) == 2 && parts[0] == key {
+			return strings.Trim(parts[1], "\"'"), nil
+		}
+	}
+	return "", fmt.Errorf("key %s not found in %s", key, path)
+}
+
+//LoadLabels creates labels from configuration values. Static labels are in format "<label>=<value>,...",
+//environment labels in format "<label>=<variable>,..." and file labels in format "<label>=<path>[:<key>],...".
+//File labels contain either whole content of the file or value of the given key in the file.
+//Labels from environment variables which are not set are skipped.
+func LoadLabels(static string, env string, files string) (map[string]string, error) {
+	labels, err := parsePairs(static)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse static labels: %s", err)
+	}
+
+	envLabels, err := parsePairs(env)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse environment labels: %s", err)
+	}
+	for label, variable := range envLabels {
+		if value, ok := os.LookupEnv(variable); ok {
+			labels[label] = value
+		}
+	}
+
+	fileLabels, err := parsePairs(files)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse file labels: %s", err)
+	}
+	for label, spec := range fileLabels {
+		parts := strings.SplitN(spec, ":", 2)
+		key := ""
+		if len(parts) == 2 {
+			key = parts[1]
+		}
+		value, err := readFileLabel(parts[0], key)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read label %s: %s", label, err)
+		}
+		labels[label] = value
+	}
+	return labels, nil
+}
+
+//ResultLabels returns global labels merged with labels from definition of the check
+func ResultLabels(input sensu.Result) map[string]string {
+	output := make(map[string]string)
+	for key, value := range Labels {
+		output[key] = value
+	}
+	for key, value := range input.Definition.Labels {
+		output[key] = value
+	}
+	return output
+}
@@ -0,0 +1,111 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/infrawatch/collectd-sensubility/sensu"
+)
+
+//Formats of metric messages
+const (
+	MetricsFormatCollectd   = "collectd"
+	MetricsFormatPrometheus = "prometheus"
+)
+
+//MetricsPlugin is used as plugin name in collectd metrics and as prefix of Prometheus metric names
+const MetricsPlugin = "sensubility"
+
+var (
+	prometheusNameRegexp   = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+	prometheusLabelRegexp  = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	prometheusValueEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+)
+
+//CollectdMetric is a value list in JSON format used by collectd write plugins
+type CollectdMetric struct {
+	Values         []float64         `json:"values"`
+	DSTypes        []string          `json:"dstypes"`
+	DSNames        []string          `json:"dsnames"`
+	Time           float64           `json:"time"`
+	Interval       float64           `json:"interval"`
+	Host           string            `json:"host"`
+	Plugin         string            `json:"plugin"`
+	PluginInstance string            `json:"plugin_instance"`
+	Type           string            `json:"type"`
+	TypeInstance   string            `json:"type_instance"`
+	Meta           map[string]string `json:"meta,omitempty"`
+}
+
+//CreateCollectdMetrics formats metrics of the check result as collectd value lists
+func CreateCollectdMetrics(input sensu.Result) []CollectdMetric {
+	output := make([]CollectdMetric, 0, len(input.Metrics))
+	for _, metric := range input.Metrics {
+		meta := make(map[string]string)
+		for key, value := range metric.Tags {
+			meta[key] = value
+		}
+		if metric.Unit != "" {
+			meta["unit"] = metric.Unit
+		}
+		output = append(output, CollectdMetric{
+			Values:         []float64{metric.Value},
+			DSTypes:        []string{"gauge"},
+			DSNames:        []string{"value"},
+			Time:           float64(metric.Timestamp),
+			Interval:       float64(input.Definition.Interval),
+			Host:           input.Client,
+			Plugin:         MetricsPlugin,
+			PluginInstance: input.Result.Name,
+			Type:           "gauge",
+			TypeInstance:   metric.Name,
+			Meta:           meta,
+		})
+	}
+	return output
+}
+
+//CreatePrometheusMetrics formats metrics of the check result in Prometheus text exposition format
+func CreatePrometheusMetrics(input sensu.Result) string {
+	var output strings.Builder
+	for _, metric := range input.Metrics {
+		labels := make(map[string]string)
+		for key, value := range metric.Tags {
+			labels[prometheusLabelRegexp.ReplaceAllString(key, "_")] = value
+		}
+		if metric.Unit != "" {
+			labels["unit"] = metric.Unit
+		}
+		// reserved labels are applied last, so that tags cannot overwrite them
+		labels["client"] = input.Client
+		labels["check"] = input.Result.Name
+		keys := make([]string, 0, len(labels))
+		for key := range labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(keys))
+		for _, key := range keys {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", key, prometheusValueEscaper.Replace(labels[key])))
+		}
+
+		name := prometheusNameRegexp.ReplaceAllString(fmt.Sprintf("%s_%s", MetricsPlugin, metric.Name), "_")
+		fmt.Fprintf(&output, "%s{%s} %g %d\n", name, strings.Join(pairs, ","), metric.Value, metric.Timestamp*1000)
+	}
+	return output.String()
+}
+
+//CreateMetricsMessage formats metrics of the check result to message body in given format
+func CreateMetricsMessage(input sensu.Result, format string) (string, error) {
+	switch format {
+	case MetricsFormatCollectd:
+		body, err := json.Marshal(CreateCollectdMetrics(input))
+		return string(body), err
+	case MetricsFormatPrometheus:
+		return CreatePrometheusMetrics(input), nil
+	}
+	return "", fmt.Errorf("unknown metrics format: %s", format)
+}
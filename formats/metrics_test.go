@@ -0,0 +1,31 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+	"github.com/infrawatch/collectd-sensubility/sensu"
+)
+
+func TestCreatePrometheusMetricsLabels(t *testing.T) {
+	result := sensu.Result{
+		CheckResult: connector.CheckResult{Client: "node-0", Result: connector.Result{Name: "check-disk"}},
+		Metrics: []sensu.Metric{{
+			Name:      "used",
+			Value:     42,
+			Timestamp: 1600000000,
+			Unit:      "%",
+			Tags:      map[string]string{"client": "spoofed", "check": "spoofed", "mount.point": "/"},
+		}},
+	}
+	expected := `sensubility_used{check="check-disk",client="node-0",mount_point="/",unit="%"} 42 1600000000000` + "\n"
+	if output := CreatePrometheusMetrics(result); output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+
+	body, err := CreateMetricsMessage(result, MetricsFormatCollectd)
+	if err != nil || !strings.Contains(body, `"host":"node-0"`) {
+		t.Errorf("unexpected collectd metrics: %s (%v)", body, err)
+	}
+}
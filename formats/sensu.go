@@ -0,0 +1,49 @@
+package formats
+
+import (
+	"strconv"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+	"github.com/infrawatch/collectd-sensubility/sensu"
+)
+
+//SensuResult is Sensu check result extended with data which connector.Result does not contain.
+//Sensu server keeps custom attributes of check results, so the format stays compatible.
+type SensuResult struct {
+	Client string     `json:"client"`
+	Check  SensuCheck `json:"check"`
+}
+
+//SensuCheck holds check part of SensuResult
+type SensuCheck struct {
+	connector.Result
+	Labels           map[string]string      `json:"labels,omitempty"`
+	Annotations      map[string]interface{} `json:"annotations,omitempty"`
+	Hooks            []sensu.HookResult     `json:"hooks,omitempty"`
+	History          []string               `json:"history,omitempty"`
+	Occurrences      int                    `json:"occurrences,omitempty"`
+	Flapping         bool                   `json:"flapping"`
+	TotalStateChange int                    `json:"total_state_change"`
+}
+
+//CreateSensuResult formats check result to extended Sensu result. History of statuses is formatted
+//as list of strings the same way as Sensu does.
+func CreateSensuResult(input sensu.Result) SensuResult {
+	history := make([]string, 0, len(input.State.History))
+	for _, status := range input.State.History {
+		history = append(history, strconv.Itoa(status))
+	}
+	return SensuResult{
+		Client: input.Client,
+		Check: SensuCheck{
+			Result:           input.Result,
+			Labels:           ResultLabels(input),
+			Annotations:      input.Annotations,
+			Hooks:            input.Hooks,
+			History:          history,
+			Occurrences:      input.State.Occurrences,
+			Flapping:         input.State.Flapping,
+			TotalStateChange: input.State.TotalStateChange,
+		},
+	}
+}
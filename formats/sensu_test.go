@@ -0,0 +1,65 @@
+package formats
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+	"github.com/infrawatch/collectd-sensubility/sensu"
+)
+
+func TestCreateSensuResultState(t *testing.T) {
+	result := sensu.Result{
+		CheckResult: connector.CheckResult{
+			Client: "node-0",
+			Result: connector.Result{Name: "check-ntp", Command: "check-ntp.sh", Status: 2, Output: "drift"},
+		},
+		State: sensu.CheckState{History: []int{0, 2, 2}, Occurrences: 2, Flapping: true, TotalStateChange: 42},
+	}
+	data, err := json.Marshal(CreateSensuResult(result))
+	if err != nil {
+		t.Fatalf("failed to marshal result: %s", err)
+	}
+	var decoded struct {
+		Client string                 `json:"client"`
+		Check  map[string]interface{} `json:"check"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal result: %s", err)
+	}
+	check := decoded.Check
+	expected := map[string]interface{}{
+		"name":               "check-ntp",
+		"status":             float64(2),
+		"history":            []interface{}{"0", "2", "2"},
+		"occurrences":        float64(2),
+		"flapping":           true,
+		"total_state_change": float64(42),
+	}
+	for key, value := range expected {
+		if !reflect.DeepEqual(check[key], value) {
+			t.Errorf("expected %s to be %v, got %v", key, value, check[key])
+		}
+	}
+	if decoded.Client != "node-0" {
+		t.Errorf("expected client node-0, got %s", decoded.Client)
+	}
+}
+
+func TestCreateSensuResultLabels(t *testing.T) {
+	Labels = map[string]string{"cloud": "overcloud", "role": "compute"}
+	defer func() { Labels = map[string]string{} }()
+	result := sensu.Result{
+		CheckResult: connector.CheckResult{Client: "node-0", Result: connector.Result{Name: "check-ovs", Status: 3}},
+		Definition:  sensu.Check{Labels: map[string]string{"role": "networker"}},
+		Annotations: map[string]interface{}{sensu.AnnotationReason: "command not found"},
+	}
+	formatted := CreateSensuResult(result)
+	if formatted.Check.Labels["cloud"] != "overcloud" || formatted.Check.Labels["role"] != "networker" {
+		t.Errorf("unexpected labels: %v", formatted.Check.Labels)
+	}
+	if formatted.Check.Annotations[sensu.AnnotationReason] != "command not found" {
+		t.Errorf("unexpected annotations: %v", formatted.Check.Annotations)
+	}
+}
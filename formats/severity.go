@@ -0,0 +1,89 @@
+package formats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/infrawatch/collectd-sensubility/sensu"
+)
+
+//Severities of check results
+const (
+	SeverityOkay    = "OKAY"
+	SeverityWarning = "WARNING"
+	SeverityFailure = "FAILURE"
+	SeverityUnknown = "UNKNOWN"
+)
+
+//Severities lists all valid severities
+var Severities = []string{SeverityOkay, SeverityWarning, SeverityFailure, SeverityUnknown}
+
+//global severity mapping
+var (
+	//SeverityMap maps check statuses to severities. It can be changed by SetSeverityMap on startup.
+	SeverityMap = map[int]string{
+		sensu.ExitCodeSuccess:       SeverityOkay,
+		sensu.ExitCodeWarning:       SeverityWarning,
+		sensu.ExitCodeFailure:       SeverityFailure,
+		sensu.ExitCodeUnknown:       SeverityUnknown,
+		sensu.ExitCodeNotExecutable: SeverityUnknown,
+		sensu.ExitCodeNotFound:      SeverityUnknown,
+	}
+	//DefaultSeverity is used for check statuses missing in SeverityMap
+	DefaultSeverity = SeverityFailure
+)
+
+func validSeverity(severity string) (string, error) {
+	severity = strings.ToUpper(strings.TrimSpace(severity))
+	for _, valid := range Severities {
+		if severity == valid {
+			return severity, nil
+		}
+	}
+	return "", fmt.Errorf("invalid severity %s, valid severities are %v", severity, Severities)
+}
+
+//ParseSeverityMap parses mapping in format "<status>:<severity>,<status>:<severity>,..."
+func ParseSeverityMap(mapping string) (map[int]string, error) {
+	output := make(map[int]string)
+	for _, item := range strings.Split(mapping, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid severity mapping item: %s", item)
+		}
+		status, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status in severity mapping item %s: %s", item, err)
+		}
+		if output[status], err = validSeverity(parts[1]); err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+//SetSeverityMap sets global status to severity mapping from configuration values
+func SetSeverityMap(mapping string, defaultSeverity string) error {
+	severityMap, err := ParseSeverityMap(mapping)
+	if err != nil {
+		return err
+	}
+	if defaultSeverity, err = validSeverity(defaultSeverity); err != nil {
+		return err
+	}
+	SeverityMap = severityMap
+	DefaultSeverity = defaultSeverity
+	return nil
+}
+
+//Severity returns severity of given check status
+func Severity(status int) string {
+	if severity, ok := SeverityMap[status]; ok {
+		return severity
+	}
+	return DefaultSeverity
+}
@@ -1,33 +1,14 @@
 package formats
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"os/exec"
-	"strings"
 	"time"
 
 	connector "github.com/infrawatch/apputils/connector/sensu"
 	"github.com/infrawatch/collectd-sensubility/sensu"
 )
 
-func sysUUID() string {
-	var out bytes.Buffer
-	cmd := exec.Command("uuidgen")
-	cmd.Stdout = &out
-	err := cmd.Run()
-	if err != nil {
-		return ""
-	}
-	return strings.TrimSpace(out.String())
-}
-
-//global indentifiers
-var (
-	DefaultHostUUID = sysUUID()
-)
-
 //SGResult is a format of event which Smart Gateway from STF understands.
 //Channel for collectd events has to be used.
 type SGResult struct {
@@ -63,29 +44,30 @@ type VESHeartBeat struct {
 }
 
 func buildVESPriority(checkResult connector.CheckResult) string {
-	if checkResult.Result.Status == sensu.ExitCodeSuccess {
+	switch Severity(checkResult.Result.Status) {
+	case SeverityOkay:
 		return "Normal"
+	case SeverityUnknown:
+		return "Medium"
 	}
 	return "High"
 }
 
 //CreateSGResult formats Sensu result so that Smart Gateway understands it
-func CreateSGResult(input connector.CheckResult) (SGResult, error) {
+func CreateSGResult(result sensu.Result) (SGResult, error) {
+	input := result.CheckResult
 	output := SGResult{
 		Labels:      make(map[string]string),
 		Annotations: make(map[string]interface{}),
 		StartsAt:    (time.Now()).Format(time.RFC3339),
 	}
 	// format collectd labes
+	for key, value := range ResultLabels(result) {
+		output.Labels[key] = value
+	}
 	output.Labels["client"] = input.Client
 	output.Labels["check"] = input.Result.Name
-	if input.Result.Status == sensu.ExitCodeSuccess {
-		output.Labels["severity"] = "OKAY"
-	} else if input.Result.Status == sensu.ExitCodeWarning {
-		output.Labels["severity"] = "WARNING"
-	} else {
-		output.Labels["severity"] = "FAILURE"
-	}
+	output.Labels["severity"] = Severity(input.Result.Status)
 	// format collectd annotations
 	output.Annotations["command"] = input.Result.Command
 	output.Annotations["issued"] = input.Result.Issued
@@ -93,6 +75,9 @@ func CreateSGResult(input connector.CheckResult) (SGResult, error) {
 	output.Annotations["duration"] = input.Result.Duration
 	output.Annotations["output"] = input.Result.Output
 	output.Annotations["status"] = input.Result.Status
+	for key, value := range result.Annotations {
+		output.Annotations[key] = value
+	}
 
 	vesData, err := json.Marshal(VESEvent{
 		Header: VESEventHeader{
@@ -100,9 +85,9 @@ func CreateSGResult(input connector.CheckResult) (SGResult, error) {
 			EventType:             "checkResult",
 			EventID:               fmt.Sprintf("%s-%s", input.Client, input.Result.Name),
 			Priority:              buildVESPriority(input),
-			ReportingEntityID:     DefaultHostUUID,
+			ReportingEntityID:     HostUUID,
 			ReportingEntityName:   input.Client,
-			SourceID:              DefaultHostUUID,
+			SourceID:              HostUUID,
 			SourceName:            fmt.Sprintf("%s-%s", input.Client, "collectd-sensubility"),
 			StartingEpochMicrosec: input.Result.Executed,
 			LastEpochMicrosec:     input.Result.Executed + int64(input.Result.Duration),
@@ -0,0 +1,109 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"time"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+	"github.com/infrawatch/collectd-sensubility/sensu"
+)
+
+//TemplateData is the data available to result templates
+type TemplateData struct {
+	connector.CheckResult
+	Check       sensu.Check
+	Metrics     []sensu.Metric
+	Annotations map[string]interface{}
+	Labels      map[string]string
+	HostUUID    string
+}
+
+//ResultTemplate renders check results using Go text/template
+type ResultTemplate struct {
+	tmpl *template.Template
+}
+
+//TemplateFuncs returns helper functions available in result templates
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		// json encodes given value to JSON, strings are quoted and escaped
+		"json": func(value interface{}) (string, error) {
+			out, err := json.Marshal(value)
+			return string(out), err
+		},
+		// formatTime formats given Unix timestamp according to given layout in UTC
+		"formatTime": func(layout string, timestamp int64) string {
+			return time.Unix(timestamp, 0).UTC().Format(layout)
+		},
+		// rfc3339 formats given Unix timestamp as RFC3339 in UTC
+		"rfc3339": func(timestamp int64) string {
+			return time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+		},
+		// now returns current time as Unix timestamp
+		"now": func() int64 {
+			return time.Now().Unix()
+		},
+		"severity": Severity,
+		"lower":    strings.ToLower,
+		"upper":    strings.ToUpper,
+		"trim":     strings.TrimSpace,
+	}
+}
+
+//LoadResultTemplate parses template from given file and validates it by rendering sample check result.
+//Missing keys of .Annotations and .Labels evaluate to zero value, because the maps differ between results.
+func LoadResultTemplate(path string) (*ResultTemplate, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read result template: %s", err)
+	}
+	tmpl, err := template.New(path).Funcs(TemplateFuncs()).Option("missingkey=zero").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse result template: %s", err)
+	}
+	output := &ResultTemplate{tmpl: tmpl}
+
+	sample := sensu.Result{
+		CheckResult: connector.CheckResult{
+			Client: "localhost",
+			Result: connector.Result{
+				Command:  "echo 'sample output' && exit 1",
+				Name:     "sample-check",
+				Issued:   time.Now().Unix(),
+				Executed: time.Now().Unix(),
+				Duration: 0.1,
+				Output:   "sample output\n",
+				Status:   sensu.ExitCodeWarning,
+			},
+		},
+		Definition:  sensu.Check{Command: "echo 'sample output' && exit 1", Interval: 10},
+		Metrics:     []sensu.Metric{{Name: "sample", Value: 1, Timestamp: time.Now().Unix()}},
+		Annotations: map[string]interface{}{},
+	}
+	if _, err := output.Render(sample); err != nil {
+		return nil, fmt.Errorf("Failed to render sample result with template: %s", err)
+	}
+	return output, nil
+}
+
+//Render renders given check result using the template
+func (rt *ResultTemplate) Render(input sensu.Result) (string, error) {
+	var output strings.Builder
+	annotations := input.Annotations
+	if annotations == nil {
+		annotations = map[string]interface{}{}
+	}
+	err := rt.tmpl.Execute(&output, TemplateData{
+		CheckResult: input.CheckResult,
+		Check:       input.Definition,
+		Metrics:     input.Metrics,
+		Annotations: annotations,
+		Labels:      ResultLabels(input),
+		HostUUID:    HostUUID,
+	})
+	return output.String(), err
+}
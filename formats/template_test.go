@@ -0,0 +1,92 @@
+package formats
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+	"github.com/infrawatch/collectd-sensubility/sensu"
+)
+
+// writeTemplate writes given template content to temporary file
+func writeTemplate(t *testing.T, content string) string {
+	templatePath := path.Join(t.TempDir(), "result.tmpl")
+	if err := ioutil.WriteFile(templatePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write template: %s", err)
+	}
+	return templatePath
+}
+
+func TestResultTemplateRender(t *testing.T) {
+	defer func(uuid string) { HostUUID = uuid }(HostUUID)
+	HostUUID = "e6d2a8c4-0f3b-4c8e-9d5a-2b7f1c3e4a5d"
+	result := sensu.Result{
+		CheckResult: connector.CheckResult{
+			Client: "node-0",
+			Result: connector.Result{Name: "check-ntp", Command: "check-ntp.sh", Status: 2, Executed: 1600000000, Output: "drift \"high\"\n"},
+		},
+		Definition: sensu.Check{Command: "check-ntp.sh", Interval: 30, Labels: map[string]string{"role": "controller"}},
+	}
+	annotated := result
+	annotated.Annotations = map[string]interface{}{sensu.AnnotationReason: "timeout"}
+
+	tests := []struct {
+		name     string
+		template string
+		result   sensu.Result
+		expected string
+	}{
+		{"result", `{"host": {{ json .Client }}, "check": {{ json .Result.Name }}, "output": {{ json (trim .Result.Output) }}}`,
+			result, `{"host": "node-0", "check": "check-ntp", "output": "drift \"high\""}`},
+		{"functions", `{{ upper (severity .Result.Status) }} {{ rfc3339 .Result.Executed }} {{ formatTime "2006-01-02" .Result.Executed }}`,
+			result, "FAILURE 2020-09-13T12:26:40Z 2020-09-13"},
+		{"definition", `{{ .Check.Command }} every {{ .Check.Interval }}s on {{ .HostUUID }}`,
+			result, "check-ntp.sh every 30s on e6d2a8c4-0f3b-4c8e-9d5a-2b7f1c3e4a5d"},
+		{"labels", `{{ .Labels.role }}/{{ .Labels.missing }}`, result, "controller/"},
+		{"missing annotation", `{"reason": {{ json .Annotations.reason }}}`, result, `{"reason": null}`},
+		{"annotation", `{"reason": {{ json .Annotations.reason }}}`, annotated, `{"reason": "timeout"}`},
+		{"range annotations", `{{ range $key, $value := .Annotations }}{{ $key }}={{ $value }}{{ end }}`, annotated, "reason=timeout"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl, err := LoadResultTemplate(writeTemplate(t, test.template))
+			if err != nil {
+				t.Fatalf("failed to load template: %s", err)
+			}
+			output, err := tmpl.Render(test.result)
+			if err != nil {
+				t.Fatalf("failed to render result: %s", err)
+			}
+			if output != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestLoadResultTemplateInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"syntax", `{"host": {{ json .Client }`, "Failed to parse result template"},
+		{"unknown function", `{{ hostname }}`, "Failed to parse result template"},
+		{"unknown field", `{{ .Result.Hostname }}`, "Failed to render sample result with template"},
+		{"invalid argument", `{{ rfc3339 .Client }}`, "Failed to render sample result with template"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadResultTemplate(writeTemplate(t, test.template))
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected error %q, got %v", test.expected, err)
+			}
+		})
+	}
+
+	if _, err := LoadResultTemplate(path.Join(t.TempDir(), "missing.tmpl")); err == nil || !strings.Contains(err.Error(), "Failed to read result template") {
+		t.Errorf("expected error for missing template, got %v", err)
+	}
+}
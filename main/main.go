@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/infrawatch/apputils/config"
 	"github.com/infrawatch/apputils/connector/amqp10"
@@ -23,8 +25,10 @@ const (
 	DefaultLogPath    = "/var/log/collectd/sensubility.log"
 	DefaultConfigPath = "/etc/collectd-sensubility.conf"
 	DefaultHostname   = "localhost.localdomain"
+	DefaultStateDir   = "/var/lib/collectd-sensubility"
 	DefaultIP         = "127.0.0.1"
 	EnvVarHostname    = "COLLECTD_HOSTNAME"
+	EnvVarInterval    = "COLLECTD_INTERVAL"
 	EnvVarConfig      = "COLLECTD_SENSUBILITY_CONFIG"
 )
 
@@ -39,6 +43,34 @@ func GetHostname() string {
 	return DefaultHostname
 }
 
+//GetCollectdInterval returns value of COLLECTD_INTERVAL env in seconds or 0 if not set. Collectd intervals
+//can be fractional.
+func GetCollectdInterval() float64 {
+	if interval, err := strconv.ParseFloat(os.Getenv(EnvVarInterval), 64); err == nil && interval > 0 {
+		return interval
+	}
+	return 0
+}
+
+//FloatValidatorFactory creates validator for checking if the validator's given value is float
+func FloatValidatorFactory() config.Validator {
+	return func(input interface{}) (interface{}, error) {
+		switch value := input.(type) {
+		case float64:
+			return value, nil
+		case int64:
+			return float64(value), nil
+		case int:
+			return float64(value), nil
+		case string:
+			if val, err := strconv.ParseFloat(value, 64); err == nil {
+				return val, nil
+			}
+		}
+		return nil, fmt.Errorf("value (%v) is not float", input)
+	}
+}
+
 //GetOutboundIP returns IP address of external interface
 func GetOutboundIP() string {
 	conn, err := net.Dial("udp", "8.8.8.8:80")
@@ -72,6 +104,36 @@ func GetAgentConfigMetadata() map[string][]config.Parameter {
 				Default:    "true",
 				Validators: []config.Validator{config.BoolValidatorFactory()},
 			},
+			{
+				Name:       "severity_map",
+				Tag:        "",
+				Default:    "0:OKAY,1:WARNING,2:FAILURE,3:UNKNOWN,126:UNKNOWN,127:UNKNOWN",
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "severity_default",
+				Tag:        "",
+				Default:    "FAILURE",
+				Validators: []config.Validator{config.StringOptionsValidatorFactory(formats.Severities)},
+			},
+			{
+				Name:       "state_dir",
+				Tag:        "",
+				Default:    DefaultStateDir,
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "host_uuid",
+				Tag:        "",
+				Default:    "",
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "host_uuid_sources",
+				Tag:        "",
+				Default:    "config,machine-id,dmi",
+				Validators: []config.Validator{},
+			},
 		},
 		"sensu": {
 			{
@@ -116,18 +178,90 @@ func GetAgentConfigMetadata() map[string][]config.Parameter {
 				Default:    "/usr/bin/sh",
 				Validators: []config.Validator{},
 			},
+			{
+				Name:       "check_user",
+				Tag:        "",
+				Default:    "",
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "check_group",
+				Tag:        "",
+				Default:    "",
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "sandbox",
+				Tag:        "",
+				Default:    "none",
+				Validators: []config.Validator{config.StringOptionsValidatorFactory([]string{"none", "remote", "all"})},
+			},
+			{
+				Name:       "sandbox_network",
+				Tag:        "",
+				Default:    "false",
+				Validators: []config.Validator{config.BoolValidatorFactory()},
+			},
+			{
+				Name:       "sandbox_binds",
+				Tag:        "",
+				Default:    "/usr,/bin,/sbin,/lib,/lib64,/etc",
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "overlap_policy",
+				Tag:        "",
+				Default:    sensu.OverlapSkip,
+				Validators: []config.Validator{config.StringOptionsValidatorFactory(sensu.OverlapPolicies)},
+			},
+			{
+				Name:       "persist_state",
+				Tag:        "",
+				Default:    "true",
+				Validators: []config.Validator{config.BoolValidatorFactory()},
+			},
+			{
+				Name:       "low_flap_threshold",
+				Tag:        "",
+				Default:    0,
+				Validators: []config.Validator{config.IntValidatorFactory()},
+			},
+			{
+				Name:       "high_flap_threshold",
+				Tag:        "",
+				Default:    0,
+				Validators: []config.Validator{config.IntValidatorFactory()},
+			},
 			{
 				Name:       "worker_count",
 				Tag:        "",
 				Default:    2,
 				Validators: []config.Validator{config.IntValidatorFactory()},
 			},
+			{
+				Name:       "max_output_size",
+				Tag:        "",
+				Default:    0,
+				Validators: []config.Validator{config.IntValidatorFactory()},
+			},
+			{
+				Name:       "parse_perfdata",
+				Tag:        "",
+				Default:    "false",
+				Validators: []config.Validator{config.BoolValidatorFactory()},
+			},
 			{
 				Name:       "checks",
 				Tag:        "",
 				Default:    "{}",
 				Validators: []config.Validator{},
 			},
+			{
+				Name:       "client_attributes",
+				Tag:        "",
+				Default:    "{}",
+				Validators: []config.Validator{},
+			},
 		},
 		"amqp1": {
 			{
@@ -158,7 +292,43 @@ func GetAgentConfigMetadata() map[string][]config.Parameter {
 				Name:       "results_format",
 				Tag:        "",
 				Default:    "smartgateway",
-				Validators: []config.Validator{config.StringOptionsValidatorFactory([]string{"smartgateway", "sensu"})},
+				Validators: []config.Validator{config.StringOptionsValidatorFactory([]string{"smartgateway", "sensu", "template"})},
+			},
+			{
+				Name:       "results_template",
+				Tag:        "",
+				Default:    "",
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "metrics_channel",
+				Tag:        "",
+				Default:    "collectd/telemetry",
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "metrics_format",
+				Tag:        "",
+				Default:    "collectd",
+				Validators: []config.Validator{config.StringOptionsValidatorFactory([]string{"collectd", "prometheus"})},
+			},
+			{
+				Name:       "suppress_flapping",
+				Tag:        "",
+				Default:    "false",
+				Validators: []config.Validator{config.BoolValidatorFactory()},
+			},
+			{
+				Name:       "state_changes_only",
+				Tag:        "",
+				Default:    "false",
+				Validators: []config.Validator{config.BoolValidatorFactory()},
+			},
+			{
+				Name:       "resync_interval",
+				Tag:        "",
+				Default:    3600,
+				Validators: []config.Validator{config.IntValidatorFactory()},
 			},
 			{
 				Name:       "listen_channels",
@@ -173,11 +343,112 @@ func GetAgentConfigMetadata() map[string][]config.Parameter {
 				Validators: []config.Validator{config.IntValidatorFactory()},
 			},
 		},
+		"labels": {
+			{
+				Name:       "static",
+				Tag:        "",
+				Default:    "",
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "env",
+				Tag:        "",
+				Default:    "",
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "files",
+				Tag:        "",
+				Default:    "",
+				Validators: []config.Validator{},
+			},
+		},
+		"collectd": {
+			{
+				Name:       "enabled",
+				Tag:        "",
+				Default:    "false",
+				Validators: []config.Validator{config.BoolValidatorFactory()},
+			},
+			{
+				Name:       "hostname",
+				Tag:        "",
+				Default:    GetHostname(),
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "plugin",
+				Tag:        "",
+				Default:    "sensubility",
+				Validators: []config.Validator{},
+			},
+			{
+				Name:       "interval",
+				Tag:        "",
+				Default:    GetCollectdInterval(),
+				Validators: []config.Validator{FloatValidatorFactory()},
+			},
+		},
 	}
 	return elements
 }
 
+//CreateAMQP10Messages formats check result to messages for AMQP1.0 message bus. Results of metric checks
+//are sent only as metrics unless the check failed. Results of flapping checks are not sent when suppressed
+//and results not changing status of the check are not sent when change filter is given. Template is required
+//only for "template" results format.
+func CreateAMQP10Messages(res sensu.Result, cfg *config.INIConfig, amqpAddr string, tmpl *formats.ResultTemplate, filter *sensu.ChangeFilter) ([]amqp10.AMQP10Message, error) {
+	msgs := []amqp10.AMQP10Message{}
+	suppressed := res.State.Flapping && cfg.Sections["amqp1"].Options["suppress_flapping"].GetBool()
+	event := !suppressed && (res.Definition.MetricFormat() == "" || res.Result.Status != sensu.ExitCodeSuccess)
+	if filter != nil {
+		// status is recorded even when the result is not sent, so that the filter follows the check
+		event = filter.Update(res, event)
+	}
+	if event {
+		var body []byte
+		var err error
+		switch cfg.Sections["amqp1"].Options["results_format"].GetString() {
+		case "sensu":
+			body, err = json.Marshal(formats.CreateSensuResult(res))
+		case "template":
+			var rendered string
+			rendered, err = tmpl.Render(res)
+			body = []byte(rendered)
+		default:
+			sgres, errr := formats.CreateSGResult(res)
+			if errr == nil {
+				body, err = json.Marshal(sgres)
+			} else {
+				err = errr
+			}
+		}
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, amqp10.AMQP10Message{
+			Address: amqpAddr,
+			Body:    string(body),
+		})
+	}
+
+	if len(res.Metrics) > 0 {
+		body, err := formats.CreateMetricsMessage(res, cfg.Sections["amqp1"].Options["metrics_format"].GetString())
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, amqp10.AMQP10Message{
+			Address: cfg.Sections["amqp1"].Options["metrics_channel"].GetString(),
+			Body:    body,
+		})
+	}
+	return msgs, nil
+}
+
 func main() {
+	// the binary is re-executed to apply resource limits before executing check commands
+	sensu.RunHelper()
+
 	debug := flag.Bool("debug", false, "enables debugging logs")
 	verbose := flag.Bool("verbose", false, "enables informational logs")
 	silent := flag.Bool("silent", false, "disables all logs except fatal errors")
@@ -195,7 +466,7 @@ func main() {
 	}
 	log, err := logging.NewLogger(level, *logpath)
 	if err != nil {
-		fmt.Printf("Failed to open log file %s.\n", *logpath)
+		fmt.Fprintf(os.Stderr, "Failed to open log file %s.\n", *logpath)
 		os.Exit(2)
 	}
 
@@ -209,7 +480,7 @@ func main() {
 	err = cfg.Parse(confPath)
 	if err != nil {
 		log.Destroy()
-		fmt.Printf("Failed to parse config file: %s\n", err.Error())
+		fmt.Fprintf(os.Stderr, "Failed to parse config file: %s\n", err.Error())
 		os.Exit(2)
 	}
 
@@ -218,7 +489,7 @@ func main() {
 	if err == nil && logFile.GetString() != "" {
 		log, err = logging.NewLogger(level, logFile.GetString())
 		if err != nil {
-			fmt.Printf("Failed to open log file %s.\n", logFile)
+			fmt.Fprintf(os.Stderr, "Failed to open log file %s.\n", logFile.GetString())
 			os.Exit(2)
 		}
 		defer log.Destroy()
@@ -246,12 +517,63 @@ func main() {
 		log.SetLogLevel(confLevel)
 	}
 
+	// set status to severity mapping used in formatted results
+	err = formats.SetSeverityMap(
+		cfg.Sections["default"].Options["severity_map"].GetString(),
+		cfg.Sections["default"].Options["severity_default"].GetString(),
+	)
+	if err != nil {
+		log.Metadata(map[string]interface{}{"error": err})
+		log.Error("Failed to parse severity mapping.")
+		os.Exit(2)
+	}
+
+	// load labels added to formatted results
+	formats.Labels, err = formats.LoadLabels(
+		cfg.Sections["labels"].Options["static"].GetString(),
+		cfg.Sections["labels"].Options["env"].GetString(),
+		cfg.Sections["labels"].Options["files"].GetString(),
+	)
+	if err != nil {
+		log.Metadata(map[string]interface{}{"error": err})
+		log.Error("Failed to load labels.")
+		os.Exit(2)
+	}
+
+	// resolve host identity used in formatted results
+	stateDir := cfg.Sections["default"].Options["state_dir"].GetString()
+	formats.HostUUID, err = formats.LoadHostUUID(
+		cfg.Sections["default"].Options["host_uuid_sources"].GetStrings(","),
+		cfg.Sections["default"].Options["host_uuid"].GetString(),
+		stateDir,
+	)
+	if err != nil {
+		log.Metadata(map[string]interface{}{"error": err, "state_dir": stateDir, "uuid": formats.HostUUID})
+		if formats.HostUUID == "" {
+			log.Error("Failed to resolve host identity.")
+			os.Exit(2)
+		}
+		log.Warn("Failed to persist host identity.")
+	}
+	sensu.StateDir = stateDir
+
+	var store *sensu.StateStore
+	if cfg.Sections["sensu"].Options["persist_state"].GetBool() {
+		store, err = sensu.LoadStateStore()
+		if err != nil {
+			log.Metadata(map[string]interface{}{"error": err, "state_dir": sensu.StateDir})
+			log.Warn("Failed to load state of checks, checks start with empty state.")
+		}
+	}
+
 	requests := make(chan interface{})
 	sensuResults := make(chan interface{})
 	amqpResults := make(chan interface{})
+	collectdResults := make(chan interface{})
 	wait := make(chan bool)
 	defer close(sensuResults)
 	defer close(amqpResults)
+	defer close(collectdResults)
 
 	reportSensu := false
 	sensuConnector := &connector.SensuConnector{}
@@ -274,10 +596,27 @@ func main() {
 	reportAmqp := false
 	amqpAddr := "collectd/events"
 	amqpConnector := &amqp10.AMQP10Connector{}
+	var amqpTemplate *formats.ResultTemplate
+	var changeFilter *sensu.ChangeFilter
 	var amqpWg *sync.WaitGroup
 	if sect, ok := cfg.Sections["amqp1"]; ok {
 		if opt, ok := sect.Options["connection"]; ok {
 			if len(opt.GetString()) > 0 {
+				if cfg.Sections["amqp1"].Options["results_format"].GetString() == "template" {
+					tmplPath := cfg.Sections["amqp1"].Options["results_template"].GetString()
+					amqpTemplate, err = formats.LoadResultTemplate(tmplPath)
+					if err != nil {
+						log.Metadata(map[string]interface{}{"error": err, "template": tmplPath})
+						log.Error("Failed to load results template.")
+						os.Exit(2)
+					}
+				}
+
+				if cfg.Sections["amqp1"].Options["state_changes_only"].GetBool() {
+					resync := time.Duration(cfg.Sections["amqp1"].Options["resync_interval"].GetInt()) * time.Second
+					changeFilter = sensu.NewChangeFilter(resync, store)
+				}
+
 				amqpConnector, err = amqp10.ConnectAMQP10("sensubility", cfg, log)
 				if err != nil {
 					log.Metadata(map[string]interface{}{"error": err, "connection": opt.GetString()})
@@ -301,6 +640,25 @@ func main() {
 		}
 	}
 
+	// collectd exec plugin reads commands from our stdout
+	reportCollectd := cfg.Sections["collectd"].Options["enabled"].GetBool()
+	if reportCollectd {
+		go func() {
+			for res := range collectdResults {
+				if result, ok := res.(connector.CheckResult); ok {
+					for _, command := range formats.CreateCollectdCommands(
+						result,
+						cfg.Sections["collectd"].Options["hostname"].GetString(),
+						cfg.Sections["collectd"].Options["plugin"].GetString(),
+						cfg.Sections["collectd"].Options["interval"].GetFloat(),
+					) {
+						fmt.Println(command)
+					}
+				}
+			}
+		}()
+	}
+
 	sensuExecutor, err := sensu.NewExecutor(cfg, log)
 	if err != nil {
 		log.Metadata(map[string]interface{}{"error": err})
@@ -309,14 +667,73 @@ func main() {
 	}
 	defer sensuExecutor.Clean()
 
+	tracker := sensu.NewTracker(cfg.Sections["sensu"].Options["overlap_policy"].GetString())
+
+	lowFlap := int(cfg.Sections["sensu"].Options["low_flap_threshold"].GetInt())
+	highFlap := int(cfg.Sections["sensu"].Options["high_flap_threshold"].GetInt())
+	if err := sensu.ValidateFlapThresholds(lowFlap, highFlap); err != nil {
+		log.Metadata(map[string]interface{}{"error": err})
+		log.Error("Invalid flap detection thresholds.")
+		os.Exit(2)
+	}
+	history := sensu.NewHistory(lowFlap, highFlap)
+
 	sensuScheduler, err := sensu.NewScheduler(cfg, log)
 	if err != nil {
 		log.Metadata(map[string]interface{}{"error": err})
 		log.Error("Failed to spawn check scheduler.")
 		os.Exit(2)
 	}
+	if store != nil {
+		// state of checks removed from configuration is not restored
+		store.Prune(sensuExecutor.Checks)
+		for name, state := range store.Checks() {
+			history.Restore(name, state.CheckState)
+			if state.Executed > 0 {
+				sensuScheduler.LastExecuted[name] = time.Unix(state.Executed, 0)
+			}
+		}
+	}
 	sensuScheduler.Start(requests)
 
+	// process executes single check request and dispatches the result
+	process := func(req sensu.Request, amqpAddr string, amqpResults chan interface{}) {
+		res, err := sensuExecutor.Execute(req)
+		if err != nil {
+			reqstr := fmt.Sprintf("Request{name=%s, command=%s, issued=%d}", req.Name, req.Command, req.Issued)
+			log.Metadata(map[string]interface{}{
+				"error":   err,
+				"request": reqstr,
+			})
+			log.Error("Failed to execute requested command.")
+			return
+		}
+		tracker.Annotate(&res)
+		history.Record(&res)
+		if store != nil {
+			store.Record(res)
+		}
+		if reportSensu {
+			sensuResults <- res.CheckResult
+		}
+		if reportCollectd {
+			collectdResults <- res.CheckResult
+		}
+		if reportAmqp {
+			msgs, err := CreateAMQP10Messages(res, cfg, amqpAddr, amqpTemplate, changeFilter)
+			if err != nil {
+				log.Metadata(map[string]interface{}{
+					"error":  err,
+					"result": res,
+				})
+				log.Error("Failed to format check result.")
+			}
+			for _, msg := range msgs {
+				amqpResults <- msg
+			}
+		}
+	}
+
 	// spawn worker goroutines
 	workers := cfg.Sections["sensu"].Options["worker_count"].GetInt()
 	for i := int64(0); i < workers; i++ {
@@ -325,53 +742,37 @@ func main() {
 			for {
 				select {
 				case req := <-requests:
+					var request sensu.Request
 					switch req := req.(type) {
+					case sensu.Request:
+						request = req
 					case connector.CheckRequest:
-						res, err := sensuExecutor.Execute(req)
-						if err != nil {
-							reqstr := fmt.Sprintf("Request{name=%s, command=%s, issued=%d}", req.Name, req.Command, req.Issued)
-							log.Metadata(map[string]interface{}{
-								"error":   err,
-								"request": reqstr,
-							})
-							log.Error("Failed to execute requested command.")
-							continue
-						}
-						if reportSensu {
-							sensuResults <- res
-						}
-						if reportAmqp {
-							var body []byte
-							if cfg.Sections["amqp1"].Options["results_format"].GetString() == "sensu" {
-								body, err = json.Marshal(res)
-							} else {
-								sgres, errr := formats.CreateSGResult(res)
-								if errr == nil {
-									body, err = json.Marshal(sgres)
-								} else {
-									err = errr
-								}
-							}
-							if err != nil {
-								log.Metadata(map[string]interface{}{
-									"error":  err,
-									"result": res,
-								})
-								log.Error("Failed to marshal check result.")
-								continue
-							}
-							msg := amqp10.AMQP10Message{
-								Address: *amqpAddr,
-								Body:    string(body),
-							}
-							amqpResults <- msg
-						}
+						// requests from Sensu server carry only attributes of connector.CheckRequest
+						request = sensu.Request{CheckRequest: req}
 					default:
 						log.Metadata(map[string]interface{}{
 							"type":    fmt.Sprintf("%T", req),
 							"request": req,
 						})
 						log.Error("Invalid type of execution request.")
+						continue
+					}
+					if ok, queued := tracker.Acquire(request); !ok {
+						log.Metadata(map[string]interface{}{
+							"check":   request.Name,
+							"policy":  tracker.Policy,
+							"skipped": tracker.Skipped(request.Name),
+						})
+						if queued {
+							log.Debug("Check is still running, execution queued.")
+						} else {
+							log.Warn("Check is still running, execution skipped.")
+						}
+						continue
+					}
+					// executions queued meanwhile are processed by the same worker
+					for next := &request; next != nil; next = tracker.Release(next.Name) {
+						process(*next, *amqpAddr, amqpResults)
 					}
 				case <-wait:
 					log.Metadata(logging.Metadata{"id": wid})
@@ -382,8 +783,29 @@ func main() {
 		}(i, &amqpAddr, amqpResults)
 	}
 
-	system.SpawnSignalHandler(wait, log, syscall.SIGINT, syscall.SIGKILL)
+	// state of checks is persisted in batches instead of on every result
+	flushStore := func() {
+		if err := store.Flush(); err != nil {
+			log.Metadata(map[string]interface{}{"error": err, "state_dir": sensu.StateDir})
+			log.Warn("Failed to persist state of checks.")
+		}
+	}
+	if store != nil {
+		go func() {
+			for range time.Tick(sensu.StateFlushInterval) {
+				flushStore()
+			}
+		}()
+	}
+
+	system.SpawnSignalHandler(wait, log, syscall.SIGINT, syscall.SIGTERM)
 	<-wait
+	// checks waiting for retry report their last result instead of delaying the shutdown
+	sensuExecutor.Stop()
+
+	if store != nil {
+		flushStore()
+	}
 
 	if reportAmqp {
 		amqpConnector.Disconnect()
@@ -0,0 +1,72 @@
+package sensu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitCommand splits command line to arguments the same way as shell does for simple commands.
+// Arguments can be quoted by single or double quotes and characters can be escaped by backslash,
+// but no expansions are performed.
+func splitCommand(command string) ([]string, error) {
+	args := []string{}
+	var arg strings.Builder
+	inArg := false
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				arg.WriteByte(c)
+			}
+		case c == '\\' && i+1 < len(command) && (quote == 0 || strings.IndexByte("\"\\$`", command[i+1]) >= 0):
+			i++
+			arg.WriteByte(command[i])
+			inArg = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				arg.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command: %s", command)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// argv returns arguments of the check command when the check is executed directly without shell,
+// otherwise nil is returned
+func (check Check) argv() ([]string, error) {
+	if len(check.Argv) > 0 {
+		return check.Argv, nil
+	}
+	if !check.Direct {
+		return nil, nil
+	}
+	args, err := splitCommand(check.Command)
+	if err == nil && len(args) == 0 {
+		err = fmt.Errorf("empty command")
+	}
+	return args, err
+}
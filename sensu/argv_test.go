@@ -0,0 +1,59 @@
+package sensu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		invalid bool
+	}{
+		{"check-disk -w 80", []string{"check-disk", "-w", "80"}, false},
+		{"  check-disk\t-w\n80  ", []string{"check-disk", "-w", "80"}, false},
+		{`check 'single quoted' "double quoted"`, []string{"check", "single quoted", "double quoted"}, false},
+		{`check '' ""`, []string{"check", "", ""}, false},
+		{`check pre'fix'"ed"`, []string{"check", "prefixed"}, false},
+		{`check 'a\b "c"'`, []string{"check", `a\b "c"`}, false},
+		{`check "a\"b \\ \$x \n"`, []string{"check", `a"b \ $x \n`}, false},
+		{`check a\ b \'c\'`, []string{"check", "a b", "'c'"}, false},
+		{`check $HOME * ; | &&`, []string{"check", "$HOME", "*", ";", "|", "&&"}, false},
+		{"", []string{}, false},
+		{`check 'unterminated`, nil, true},
+		{`check "unterminated`, nil, true},
+	}
+	for _, test := range tests {
+		args, err := splitCommand(test.command)
+		if (err != nil) != test.invalid {
+			t.Errorf("%s: expected invalid %t, got error %v", test.command, test.invalid, err)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%s: expected %q, got %q", test.command, test.args, args)
+		}
+	}
+}
+
+func TestCheckArgv(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   Check
+		args    []string
+		invalid bool
+	}{
+		{"shell", Check{Command: "check-disk -w 80"}, nil, false},
+		{"direct", Check{Command: "check-disk -w '8 0'", Direct: true}, []string{"check-disk", "-w", "8 0"}, false},
+		{"argv", Check{Command: "ignored", Argv: []string{"check-disk", "-w"}}, []string{"check-disk", "-w"}, false},
+		{"empty", Check{Command: " ", Direct: true}, []string{}, true},
+	}
+	for _, test := range tests {
+		args, err := test.check.argv()
+		if (err != nil) != test.invalid {
+			t.Errorf("%s: expected invalid %t, got error %v", test.name, test.invalid, err)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.args, args)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package sensu
+
+import (
+	"sync"
+	"time"
+)
+
+// published is last status of the check together with time of the last publication. Pending is set
+// when the status was not published yet.
+type published struct {
+	Status    int
+	Published int64
+	Pending   bool
+}
+
+//ChangeFilter remembers last status of each check and lets through only results changing it. Results are
+//let through regardless of status once resync interval passed since the last publication, zero interval
+//disables the resync. Published statuses are persisted in state store when the filter has one.
+type ChangeFilter struct {
+	Resync time.Duration
+	store  *StateStore
+	lock   sync.Mutex
+	last   map[string]published
+}
+
+//NewChangeFilter creates filter with given resync interval. Published statuses are loaded from given
+//state store and persisted in it, nil store keeps them only in memory.
+func NewChangeFilter(resync time.Duration, store *StateStore) *ChangeFilter {
+	filter := ChangeFilter{
+		Resync: resync,
+		store:  store,
+		last:   make(map[string]published),
+	}
+	if store == nil {
+		return &filter
+	}
+	for name, check := range store.Checks() {
+		// checks without publication were not filtered by previous run of the agent
+		if check.Published > 0 || check.Pending {
+			filter.last[name] = published{Status: check.Status, Published: check.Published, Pending: check.Pending}
+		}
+	}
+	return &filter
+}
+
+//Update records status of given result and returns true if the result should be published, either because
+//status of the check changed since the last result, status change was not published yet or resync interval
+//passed since the last published result. Results which are not going to be published (eg. suppressed results
+//of flapping checks) have to be recorded too with publish false, so that the filter follows status of the check.
+func (self *ChangeFilter) Update(result Result, publish bool) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	now := time.Now()
+	last, ok := self.last[result.Result.Name]
+	changed := !ok || last.Status != result.Result.Status
+	due := changed || last.Pending || (self.Resync > 0 && now.Sub(time.Unix(last.Published, 0)) >= self.Resync)
+	publish = publish && due
+	if !changed && !publish {
+		return false
+	}
+	last.Status = result.Result.Status
+	if publish {
+		last.Published = now.Unix()
+		last.Pending = false
+	} else {
+		last.Pending = true
+	}
+	self.last[result.Result.Name] = last
+	if self.store != nil {
+		self.store.RecordPublished(result.Result.Name, last.Published, last.Pending)
+	}
+	return publish
+}
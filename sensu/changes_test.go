@@ -0,0 +1,69 @@
+package sensu
+
+import (
+	"testing"
+	"time"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+)
+
+func TestChangeFilter(t *testing.T) {
+	filter := NewChangeFilter(0, nil)
+	steps := []struct {
+		status    int
+		publish   bool
+		published bool
+	}{
+		{ExitCodeSuccess, true, true},
+		{ExitCodeSuccess, true, false},
+		{ExitCodeFailure, true, true},
+		// status changes of suppressed results are recorded, but not published
+		{ExitCodeSuccess, false, false},
+		{ExitCodeFailure, false, false},
+		{ExitCodeWarning, false, false},
+		// pending change is published once results are not suppressed
+		{ExitCodeWarning, true, true},
+		{ExitCodeWarning, true, false},
+		{ExitCodeSuccess, true, true},
+	}
+	for i, step := range steps {
+		result := Result{CheckResult: connector.CheckResult{Result: connector.Result{Name: "check", Status: step.status}}}
+		if published := filter.Update(result, step.publish); published != step.published {
+			t.Errorf("step %d: expected published %t, got %t", i, step.published, published)
+		}
+	}
+}
+
+func TestChangeFilterRestore(t *testing.T) {
+	defer func(dir string) { StateDir = dir }(StateDir)
+	StateDir = t.TempDir()
+
+	update := func(filter *ChangeFilter, store *StateStore, name string, status int) bool {
+		result := Result{CheckResult: connector.CheckResult{Result: connector.Result{Name: name, Status: status, Executed: 1600000000}}}
+		store.Record(result)
+		return filter.Update(result, true)
+	}
+
+	store, err := LoadStateStore()
+	if err != nil {
+		t.Fatalf("failed to load store: %s", err)
+	}
+	filter := NewChangeFilter(time.Hour, store)
+	update(filter, store, "check-ntp", ExitCodeSuccess)
+	update(filter, store, "check-ovs", ExitCodeFailure)
+	if err := store.Flush(); err != nil {
+		t.Fatalf("failed to flush store: %s", err)
+	}
+
+	// restarted agent does not publish unchanged statuses again
+	if store, err = LoadStateStore(); err != nil {
+		t.Fatalf("failed to reload store: %s", err)
+	}
+	filter = NewChangeFilter(time.Hour, store)
+	if update(filter, store, "check-ntp", ExitCodeSuccess) {
+		t.Errorf("expected unchanged status not to be published after restart")
+	}
+	if !update(filter, store, "check-ovs", ExitCodeSuccess) {
+		t.Errorf("expected changed status to be published after restart")
+	}
+}
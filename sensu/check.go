@@ -0,0 +1,140 @@
+package sensu
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/infrawatch/apputils/config"
+)
+
+// Check holds data for single Sensu check to be scheduled
+type Check struct {
+	Command            string            `json:"command"`
+	Subscribers        []string          `json:"subscribers"`
+	Interval           int               `json:"interval"`
+	Timeout            int               `json:"timeout"`
+	TTL                int               `json:"ttl"`
+	TTLStatus          int               `json:"ttl_status"`
+	Occurrences        int               `json:"occurrences"`
+	Refresh            int               `json:"refresh"`
+	Handlers           []string          `json:"handlers"`
+	Dependencies       []string          `json:"dependencies"`
+	Type               string            `json:"type"`
+	OutputMetricFormat string            `json:"output_metric_format"`
+	Labels             map[string]string `json:"labels"`
+	User               string            `json:"user"`
+	Group              string            `json:"group"`
+	Limits             Limits            `json:"limits"`
+	Sandbox            *bool             `json:"sandbox"`
+	Env                map[string]string `json:"env"`
+	Cwd                string            `json:"cwd"`
+	Argv               []string          `json:"argv"`
+	Direct             bool              `json:"direct"`
+	Interpreter        string            `json:"interpreter"`
+	Hooks              map[string]Hook   `json:"hooks"`
+	Params             json.RawMessage   `json:"params"`
+	Stdin              bool              `json:"stdin"`
+	Retries            int               `json:"retries"`
+	RetryInterval      int               `json:"retry_interval"`
+	LowFlapThreshold   int               `json:"low_flap_threshold"`
+	HighFlapThreshold  int               `json:"high_flap_threshold"`
+	remote             bool
+}
+
+// MetricFormat returns format of metrics in the check output or empty string
+// if the check does not output metrics. Sensu 1.x metric checks output Graphite plaintext.
+func (check Check) MetricFormat() string {
+	if check.OutputMetricFormat != "" {
+		return check.OutputMetricFormat
+	}
+	if check.Type == CheckTypeMetric {
+		return MetricFormatGraphite
+	}
+	return ""
+}
+
+// Validate checks validity of the check definition
+func (check Check) Validate() error {
+	switch check.Type {
+	case "", CheckTypeStandard, CheckTypeMetric:
+		if len(check.Params) > 0 {
+			return fmt.Errorf("params can be used only by native checks")
+		}
+	default:
+		factory, ok := nativeChecks[check.Type]
+		if !ok {
+			return fmt.Errorf("unknown check type: %s", check.Type)
+		}
+		// parameters with tokens can be validated only after substitution on execution
+		if !tokenRegexp.Match(check.Params) {
+			if _, err := factory(check.Params); err != nil {
+				return fmt.Errorf("invalid %s check: %s", check.Type, err)
+			}
+		}
+		if check.Command != "" || len(check.Argv) > 0 || check.Interpreter != "" || check.OutputMetricFormat != "" || check.Stdin {
+			return fmt.Errorf("native checks do not execute any command")
+		}
+	}
+	if format := check.MetricFormat(); format != "" {
+		known := false
+		for _, supported := range MetricFormats {
+			known = known || format == supported
+		}
+		if !known {
+			return fmt.Errorf("unknown output metric format: %s", format)
+		}
+	}
+	if len(check.Argv) > 0 {
+		if check.Command != "" || check.Direct {
+			return fmt.Errorf("argv cannot be combined with command or direct")
+		}
+		if check.Argv[0] == "" {
+			return fmt.Errorf("empty command in argv")
+		}
+	}
+	if check.Interpreter != "" && (len(check.Argv) > 0 || check.Direct) {
+		return fmt.Errorf("interpreter cannot be used for checks executed directly")
+	}
+	if check.Direct && check.Command != "" {
+		if _, err := check.argv(); err != nil {
+			return err
+		}
+	}
+	for key := range check.Env {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			return fmt.Errorf("invalid environment variable name: %q", key)
+		}
+	}
+	if check.Retries < 0 || check.RetryInterval < 0 {
+		return fmt.Errorf("retries and retry interval cannot be negative")
+	}
+	if err := ValidateFlapThresholds(check.LowFlapThreshold, check.HighFlapThreshold); err != nil {
+		return err
+	}
+	if err := validateHooks(check.Hooks); err != nil {
+		return err
+	}
+	if err := check.Limits.Validate(); err != nil {
+		return err
+	}
+	if check.Sandbox != nil && *check.Sandbox && !helperSupported {
+		return fmt.Errorf("sandbox is not supported on this platform")
+	}
+	return nil
+}
+
+// LoadChecks parses locally defined checks from configuration
+func LoadChecks(cfg *config.INIConfig) (map[string]Check, error) {
+	checks := make(map[string]Check)
+	err := json.Unmarshal(cfg.Sections["sensu"].Options["checks"].GetBytes(), &checks)
+	if err != nil {
+		return nil, err
+	}
+	for name, check := range checks {
+		if err := check.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid definition of check %s: %s", name, err)
+		}
+	}
+	return checks, nil
+}
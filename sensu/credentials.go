@@ -0,0 +1,104 @@
+package sensu
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// lookupCredential resolves given user and group names (or IDs) to credential usable for process execution.
+// User's primary group is used if group is not given and current user is used if only group is given.
+// Returns nil when neither user nor group is given.
+func lookupCredential(userName string, groupName string) (*syscall.Credential, error) {
+	if userName == "" && groupName == "" {
+		return nil, nil
+	}
+
+	cred := &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())}
+	if userName != "" {
+		usr, err := user.Lookup(userName)
+		if err != nil {
+			if usr, err = user.LookupId(userName); err != nil {
+				return nil, fmt.Errorf("unknown user %s", userName)
+			}
+		}
+		uid, err := strconv.ParseUint(usr.Uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uid of user %s: %s", userName, usr.Uid)
+		}
+		gid, err := strconv.ParseUint(usr.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gid of user %s: %s", userName, usr.Gid)
+		}
+		cred.Uid = uint32(uid)
+		cred.Gid = uint32(gid)
+
+		groups, err := usr.GroupIds()
+		if err == nil {
+			for _, group := range groups {
+				if id, err := strconv.ParseUint(group, 10, 32); err == nil {
+					cred.Groups = append(cred.Groups, uint32(id))
+				}
+			}
+		}
+	}
+	if groupName != "" {
+		grp, err := user.LookupGroup(groupName)
+		if err != nil {
+			if grp, err = user.LookupGroupId(groupName); err != nil {
+				return nil, fmt.Errorf("unknown group %s", groupName)
+			}
+		}
+		gid, err := strconv.ParseUint(grp.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gid of group %s: %s", groupName, grp.Gid)
+		}
+		cred.Gid = uint32(gid)
+	}
+	return cred, nil
+}
+
+// credentialNames returns user and group under which given check should be executed
+func (self *Executor) credentialNames(check Check) (string, string) {
+	if check.User != "" {
+		return check.User, check.Group
+	}
+	if check.Group != "" {
+		return self.DefaultUser, check.Group
+	}
+	return self.DefaultUser, self.DefaultGroup
+}
+
+// loadCredentials resolves and caches credentials of default user and of all locally defined checks
+func (self *Executor) loadCredentials() error {
+	self.credentials = make(map[string]*syscall.Credential)
+	checks := map[string]Check{"": {}}
+	for name, check := range self.Checks {
+		checks[name] = check
+	}
+	for name, check := range checks {
+		userName, groupName := self.credentialNames(check)
+		key := fmt.Sprintf("%s:%s", userName, groupName)
+		if _, ok := self.credentials[key]; ok {
+			continue
+		}
+		cred, err := lookupCredential(userName, groupName)
+		if err != nil {
+			if name == "" {
+				return fmt.Errorf("invalid default check credentials: %s", err)
+			}
+			return fmt.Errorf("invalid credentials of check %s: %s", name, err)
+		}
+		self.credentials[key] = cred
+	}
+	return nil
+}
+
+// credential returns credential under which given check should be executed or nil
+// if the check should be executed under the agent's identity
+func (self *Executor) credential(check Check) *syscall.Credential {
+	userName, groupName := self.credentialNames(check)
+	return self.credentials[fmt.Sprintf("%s:%s", userName, groupName)]
+}
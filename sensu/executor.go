@@ -1,10 +1,18 @@
 package sensu
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -18,15 +26,77 @@ const (
 	ExitCodeSuccess = iota
 	ExitCodeWarning
 	ExitCodeFailure
+	ExitCodeUnknown
 )
 
+// Return codes of shell for commands which could not be executed or were killed by signal.
+// Shell reports command killed by signal N as exit status ExitCodeSignalBase+N.
+const (
+	ExitCodeNotExecutable = 126
+	ExitCodeNotFound      = 127
+	ExitCodeSignalBase    = 128
+)
+
+//MaxRetryInterval caps retry interval of checks, which doubles with each retry
+const MaxRetryInterval = 5 * time.Minute
+
+// maxSignal is the highest signal number, statuses above ExitCodeSignalBase+maxSignal are plain exit codes
+const maxSignal = 64
+
+// Result annotations
+const (
+	// AnnotationReason explains abnormal check termination
+	AnnotationReason = "reason"
+	// AnnotationStderr holds standard error output of the check
+	AnnotationStderr = "stderr"
+	// AnnotationTruncated is set when check output exceeded maximal output size
+	AnnotationTruncated = "output_truncated"
+	// AnnotationSkipped holds number of executions skipped since previous result because the check was still running
+	AnnotationSkipped = "skipped_executions"
+	// AnnotationHooks holds results of check hooks executed for the check status
+	AnnotationHooks = "hooks"
+	// AnnotationAttempts holds number of check executions for checks with retries
+	AnnotationAttempts = "attempts"
+)
+
+//Request is check request together with attributes of Sensu check requests which connector.CheckRequest
+//does not contain. Requests received from Sensu server by connector.SensuConnector carry only attributes
+//of connector.CheckRequest.
+type Request struct {
+	connector.CheckRequest
+	Stdin bool `json:"stdin,omitempty"`
+}
+
+//Result holds check result in Sensu format together with data which Sensu format cannot carry
+type Result struct {
+	connector.CheckResult
+	Definition  Check
+	Metrics     []Metric
+	Hooks       []HookResult
+	State       CheckState
+	Annotations map[string]interface{}
+}
+
 //Executor executes checks based on incoming requests
 type Executor struct {
-	ClientName  string
-	TmpBaseDir  string
-	ShellPath   string
-	log         *logging.Logger
-	scriptCache map[string]string
+	ClientName       string
+	TmpBaseDir       string
+	ShellPath        string
+	ParsePerfdata    bool
+	MaxOutputSize    int
+	SandboxMode      string
+	SandboxNetwork   bool
+	SandboxBinds     []string
+	DefaultUser      string
+	DefaultGroup     string
+	ClientAttributes map[string]interface{}
+	Checks           map[string]Check
+	log              *logging.Logger
+	credentials      map[string]*syscall.Credential
+	scriptCache      map[string]string
+	scriptLock       sync.Mutex
+	done             chan struct{}
+	doneLock         sync.Mutex
 }
 
 //NewExecutor creates and initialize executor struct
@@ -35,6 +105,38 @@ func NewExecutor(cfg *config.INIConfig, logger *logging.Logger) (*Executor, erro
 	executor.ClientName = cfg.Sections["sensu"].Options["client_name"].GetString()
 	executor.TmpBaseDir = cfg.Sections["sensu"].Options["tmp_base_dir"].GetString()
 	executor.ShellPath = cfg.Sections["sensu"].Options["shell_path"].GetString()
+	executor.ParsePerfdata = cfg.Sections["sensu"].Options["parse_perfdata"].GetBool()
+	executor.MaxOutputSize = int(cfg.Sections["sensu"].Options["max_output_size"].GetInt())
+	executor.DefaultUser = cfg.Sections["sensu"].Options["check_user"].GetString()
+	executor.DefaultGroup = cfg.Sections["sensu"].Options["check_group"].GetString()
+	executor.SandboxMode = cfg.Sections["sensu"].Options["sandbox"].GetString()
+	executor.SandboxNetwork = cfg.Sections["sensu"].Options["sandbox_network"].GetBool()
+	executor.SandboxBinds = cfg.Sections["sensu"].Options["sandbox_binds"].GetStrings(",")
+	if executor.SandboxMode != SandboxNone && !helperSupported {
+		return nil, fmt.Errorf("sandbox is not supported on this platform")
+	}
+
+	//client attributes are used for token substitution in check definitions
+	executor.ClientAttributes = make(map[string]interface{})
+	if err := json.Unmarshal(cfg.Sections["sensu"].Options["client_attributes"].GetBytes(), &executor.ClientAttributes); err != nil {
+		return nil, fmt.Errorf("Failed to parse client attributes: %s", err)
+	}
+	executor.ClientAttributes["name"] = executor.ClientName
+	executor.ClientAttributes["address"] = cfg.Sections["sensu"].Options["client_address"].GetString()
+	subscriptions := []interface{}{}
+	for _, sub := range cfg.Sections["sensu"].Options["subscriptions"].GetStrings(",") {
+		subscriptions = append(subscriptions, sub)
+	}
+	executor.ClientAttributes["subscriptions"] = subscriptions
+
+	checks, err := LoadChecks(cfg)
+	if err != nil {
+		return nil, err
+	}
+	executor.Checks = checks
+	if err := executor.loadCredentials(); err != nil {
+		return nil, err
+	}
 
 	executor.scriptCache = make(map[string]string)
 	executor.log = logger
@@ -44,69 +146,508 @@ func NewExecutor(cfg *config.INIConfig, logger *logging.Logger) (*Executor, erro
 			return nil, err
 		}
 	}
+	for _, cred := range executor.credentials {
+		if cred != nil {
+			// checks running under different user need to reach their scripts
+			if err := os.Chmod(executor.TmpBaseDir, 0711); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
 	return &executor, nil
 }
 
-//Execute prepares script for single check based on given the request and then executes it
-func (self *Executor) Execute(request connector.CheckRequest) (connector.CheckResult, error) {
-	// It is not possible to reasonably exec something like "cmd1 && cmd2 || exit 2".
-	// This is usual in Sensu framework so we need to make temporary script for each command.
-	// To avoid high IO the script files are cached
-	if _, ok := self.scriptCache[request.Command]; !ok {
-		scriptFile, err := ioutil.TempFile(self.TmpBaseDir, "check-")
-		if err != nil {
-			return connector.CheckResult{}, fmt.Errorf("Failed to create temporary file for script: %s", err)
+//exitStatus returns check status and reason of abnormal termination according to the given
+//error from command execution. Commands killed by signal and commands which could not be started
+//at all result in unknown status. Shell has to be true for commands executed by shell.
+func exitStatus(err error, shell bool) (int, string) {
+	if err == nil {
+		return ExitCodeSuccess, ""
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		// commands executed directly fail on start the same way as they would fail in shell
+		switch {
+		case errors.Is(err, exec.ErrNotFound), errors.Is(err, os.ErrNotExist):
+			return ExitCodeNotFound, fmt.Sprintf("command not found: %s", err)
+		case errors.Is(err, os.ErrPermission):
+			return ExitCodeNotExecutable, fmt.Sprintf("command is not executable: %s", err)
 		}
-		_, err = scriptFile.Write([]byte(fmt.Sprintf("#!/usr/bin/env sh\n%s\n", request.Command)))
-		if err != nil {
-			return connector.CheckResult{}, fmt.Errorf("Failed to write script content to temporary file: %s", err)
+		return ExitCodeUnknown, fmt.Sprintf("failed to execute command: %s", err)
+	}
+	ws, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return ExitCodeFailure, ""
+	}
+	return waitStatus(ws, shell)
+}
+
+//terminatingSignal returns signal which killed the command. Shell exits with ExitCodeSignalBase+N when
+//its command is killed by signal N, so such statuses count as signals too for commands executed by shell.
+func terminatingSignal(ws syscall.WaitStatus, shell bool) (syscall.Signal, bool) {
+	if ws.Signaled() {
+		return ws.Signal(), true
+	}
+	if status := ws.ExitStatus(); shell && status > ExitCodeSignalBase && status <= ExitCodeSignalBase+maxSignal {
+		return syscall.Signal(status - ExitCodeSignalBase), true
+	}
+	return 0, false
+}
+
+//waitStatus returns check status and reason of abnormal termination according to the given wait status
+func waitStatus(ws syscall.WaitStatus, shell bool) (int, string) {
+	if signal, ok := terminatingSignal(ws, shell); ok {
+		return ExitCodeUnknown, fmt.Sprintf("killed by signal: %s", signal)
+	}
+	switch ws.ExitStatus() {
+	case ExitCodeNotExecutable:
+		return ws.ExitStatus(), "command is not executable"
+	case ExitCodeNotFound:
+		return ws.ExitStatus(), "command not found"
+	}
+	return ws.ExitStatus(), ""
+}
+
+//definition returns definition of the requested check. Local definition applies to requests of the same
+//check, that is requests with the same name and either the same or no command. Local definition without
+//command only adds its attributes to the requested command. Requests with different command are executed
+//as they are, same as requests of checks without local definition. Check input is provided when either
+//the request or the local definition asks for it.
+func (self *Executor) definition(request Request) Check {
+	remote := Check{Command: request.Command, Handlers: request.Handlers, Stdin: request.Stdin, remote: true}
+	check, ok := self.Checks[request.Name]
+	if !ok {
+		return remote
+	}
+	_, native := nativeChecks[check.Type]
+	switch {
+	case check.Command == "" && len(check.Argv) == 0 && !native:
+		check.Command = request.Command
+		check.remote = true
+	case request.Command != "" && request.Command != check.Command:
+		return remote
+	}
+	check.Stdin = check.Stdin || request.Stdin
+	return check
+}
+
+//failedResult creates result with unknown status for checks which could not be executed
+func (self *Executor) failedResult(request Request, check Check, reason string) Result {
+	self.log.Metadata(map[string]interface{}{"check": request.Name, "reason": reason})
+	self.log.Warn("Failed to execute check.")
+	return Result{
+		CheckResult: connector.CheckResult{
+			Client: self.ClientName,
+			Result: connector.Result{
+				Command:  check.Command,
+				Name:     request.Name,
+				Issued:   request.Issued,
+				Executed: time.Now().Unix(),
+				Output:   reason,
+				Status:   ExitCodeUnknown,
+			},
+		},
+		Definition:  check,
+		Annotations: map[string]interface{}{AnnotationReason: reason},
+	}
+}
+
+//script returns path to the script for given command. It is not possible to reasonably exec something
+//like "cmd1 && cmd2 || exit 2". This is usual in Sensu framework so we need to make temporary script
+//for each command. To avoid high IO the script files are cached. Scripts for checks executed under
+//different user are owned by that user.
+func (self *Executor) script(command string, interpreter string, cred *syscall.Credential) (string, error) {
+	key := fmt.Sprintf("%s:%s", interpreter, command)
+	if cred != nil {
+		key = fmt.Sprintf("%d:%d:%s", cred.Uid, cred.Gid, key)
+	}
+
+	self.scriptLock.Lock()
+	defer self.scriptLock.Unlock()
+	if path, ok := self.scriptCache[key]; ok {
+		return path, nil
+	}
+
+	scriptFile, err := ioutil.TempFile(self.TmpBaseDir, "check-")
+	if err != nil {
+		return "", fmt.Errorf("Failed to create temporary file for script: %s", err)
+	}
+	defer scriptFile.Close()
+	header := "/usr/bin/env sh"
+	if interpreter != "" {
+		header = fmt.Sprintf("/usr/bin/env %s", interpreter)
+	}
+	_, err = scriptFile.Write([]byte(fmt.Sprintf("#!%s\n%s\n", header, command)))
+	if err != nil {
+		return "", fmt.Errorf("Failed to write script content to temporary file: %s", err)
+	}
+	if cred != nil {
+		if err := scriptFile.Chown(int(cred.Uid), int(cred.Gid)); err != nil {
+			return "", fmt.Errorf("Failed to change owner of script: %s", err)
+		}
+	}
+	self.scriptCache[key] = scriptFile.Name()
+	self.log.Metadata(map[string]interface{}{"command": command, "interpreter": interpreter, "path": scriptFile.Name()})
+	self.log.Debug("Created check script.")
+	return scriptFile.Name(), nil
+}
+
+//command returns command for checks executed directly without shell, otherwise it returns nil
+func (self *Executor) command(check Check) (*exec.Cmd, error) {
+	args, err := check.argv()
+	if err != nil || args == nil {
+		return nil, err
+	}
+	return exec.Command(args[0], args[1:]...), nil
+}
+
+// pruneZero removes attributes with zero values from decoded JSON object, so that only attributes set
+// in the definition remain
+func pruneZero(object map[string]interface{}) bool {
+	for key, value := range object {
+		empty := false
+		switch typed := value.(type) {
+		case nil:
+			empty = true
+		case string:
+			empty = typed == ""
+		case bool:
+			empty = !typed
+		case float64:
+			empty = typed == 0
+		case []interface{}:
+			empty = len(typed) == 0
+		case map[string]interface{}:
+			empty = pruneZero(typed)
+		}
+		if empty {
+			delete(object, key)
+		}
+	}
+	return len(object) == 0
+}
+
+//stdin returns input of commands of checks with stdin attribute. Same as in case of Sensu client it contains
+//client attributes and check definition in JSON.
+func (self *Executor) stdin(request Request, check Check) ([]byte, error) {
+	definition := make(map[string]interface{})
+	data, err := json.Marshal(check)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &definition); err != nil {
+		return nil, err
+	}
+	pruneZero(definition)
+	definition["name"] = request.Name
+	definition["issued"] = request.Issued
+	return json.Marshal(map[string]interface{}{
+		"client": self.ClientAttributes,
+		"check":  definition,
+	})
+}
+
+// execution holds outcome of single command execution
+type execution struct {
+	status   int
+	reason   string
+	stdout   *LimitedBuffer
+	stderr   *LimitedBuffer
+	start    time.Time
+	duration time.Duration
+}
+
+// run executes given command prepared for the given check under the check's credentials, limits and sandbox.
+// Command running longer than given timeout is killed together with its children, zero timeout means no limit.
+func (self *Executor) run(check Check, cmd *exec.Cmd, timeout time.Duration) execution {
+	// exit statuses of shell reflect signals which killed its commands, unlike those of commands executed directly
+	shell := cmd.Args[0] == self.ShellPath
+	cred := self.credential(check)
+	spec := helperSpec{Limits: check.Limits}
+	if self.sandboxed(check) {
+		spec.Sandbox = &SandboxSpec{
+			Root:       path.Join(self.TmpBaseDir, sandboxRootDir),
+			Binds:      append(append([]string{}, self.SandboxBinds...), self.TmpBaseDir),
+			Network:    self.SandboxNetwork,
+			Credential: cred,
+		}
+	}
+	if cred != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+	}
+	cmd.Dir = check.Cwd
+	spec.Dir = check.Cwd
+	if len(check.Env) > 0 {
+		keys := make([]string, 0, len(check.Env))
+		for key := range check.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		cmd.Env = os.Environ()
+		for _, key := range keys {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, check.Env[key]))
+		}
+	}
+
+	outcome := execution{
+		stdout: NewLimitedBuffer(self.MaxOutputSize),
+		stderr: NewLimitedBuffer(self.MaxOutputSize),
+	}
+	var helperPipe *os.File
+	if !spec.Limits.IsZero() || spec.Sandbox != nil {
+		var err error
+		if helperPipe, err = helperCommand(cmd, spec); err != nil {
+			outcome.status, outcome.reason = ExitCodeUnknown, fmt.Sprintf("failed to prepare executor helper: %s", err)
+			return outcome
+		}
+		if spec.Sandbox != nil {
+			sandboxAttrs(cmd, spec.Sandbox)
+		}
+	}
+	cmd.Stdout = outcome.stdout
+	cmd.Stderr = outcome.stderr
+	if timeout > 0 {
+		// children of the command have to be killed too, otherwise they would keep output pipes open
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.Setpgid = true
+	}
+
+	outcome.start = time.Now()
+	var timedOut int32
+	err := cmd.Start()
+	if err == nil {
+		if timeout > 0 {
+			timer := time.AfterFunc(timeout, func() {
+				atomic.StoreInt32(&timedOut, 1)
+				syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			})
+			defer timer.Stop()
+		}
+		err = cmd.Wait()
+	}
+	outcome.duration = time.Since(outcome.start)
+	outcome.status, outcome.reason = exitStatus(err, shell)
+	if atomic.LoadInt32(&timedOut) == 1 {
+		outcome.status, outcome.reason = ExitCodeFailure, fmt.Sprintf("execution timed out after %s", timeout)
+		if helperPipe != nil {
+			readHelperStatus(cmd, helperPipe)
+		}
+		return outcome
+	}
+	if cred != nil && err != nil && outcome.status == ExitCodeUnknown {
+		if _, ok := err.(*exec.ExitError); !ok {
+			userName, groupName := self.credentialNames(check)
+			outcome.reason = fmt.Sprintf("failed to execute command as %s:%s: %s", userName, groupName, err)
+		}
+	}
+	if helperPipe != nil {
+		// resource usage reported by wait includes children of the command reaped by it, so CPU time
+		// of a check script covers commands it executed
+		ws, _ := cmd.ProcessState.Sys().(syscall.WaitStatus)
+		cpuTime := cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+		status := readHelperStatus(cmd, helperPipe)
+		if status.Error != "" {
+			outcome.status, outcome.reason = ExitCodeUnknown, fmt.Sprintf("failed to prepare command execution: %s", status.Error)
+			return outcome
+		}
+		if status.Exited {
+			ws, cpuTime = syscall.WaitStatus(status.WaitStatus), status.CPUTime
+			outcome.status, outcome.reason = waitStatus(ws, shell)
+		}
+		if reason := limitReason(check.Limits, ws, shell, cpuTime); reason != "" {
+			outcome.reason = reason
+		}
+	}
+	return outcome
+}
+
+//Execute executes single check based on the given request. Checks which did not succeed are executed
+//again up to number of their retries, with retry interval doubled after each attempt up to MaxRetryInterval.
+//Retries are not executed once the executor is stopped. Hooks are executed for the final result only.
+func (self *Executor) Execute(request Request) (Result, error) {
+	result, err := self.execute(request)
+	if err != nil || result.Definition.Retries == 0 {
+		return result, err
+	}
+	check := result.Definition
+	attempt := 1
+	delay := time.Duration(check.RetryInterval) * time.Second
+retryLoop:
+	for ; attempt <= check.Retries && result.Result.Status != ExitCodeSuccess; attempt++ {
+		if delay > MaxRetryInterval {
+			delay = MaxRetryInterval
+		}
+		self.log.Metadata(map[string]interface{}{
+			"check":   request.Name,
+			"status":  result.Result.Status,
+			"attempt": attempt,
+			"delay":   delay.String(),
+		})
+		self.log.Debug("Check did not succeed, retrying.")
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-self.stopped():
+			timer.Stop()
+			self.log.Metadata(map[string]interface{}{"check": request.Name, "attempt": attempt})
+			self.log.Debug("Executor stopped, check is not retried.")
+			break retryLoop
+		}
+		delay *= 2
+		if result, err = self.execute(request); err != nil {
+			return result, err
 		}
-		self.scriptCache[request.Command] = scriptFile.Name()
-		scriptFile.Close()
-		self.log.Metadata(map[string]interface{}{"command": request.Command, "path": scriptFile.Name()})
-		self.log.Debug("Created check script.")
 	}
+	result.Annotations[AnnotationAttempts] = attempt
+	self.hooks(&result)
+	return result, nil
+}
 
-	cmd := exec.Command(self.ShellPath, self.scriptCache[request.Command])
-	start := time.Now()
-	output, err := cmd.CombinedOutput()
-	duration := time.Since(start)
-	status := ExitCodeSuccess
+// hooks executes hooks of the check according to the status of given result
+func (self *Executor) hooks(result *Result) {
+	if len(result.Definition.Hooks) > 0 {
+		result.Hooks = self.runHooks(result.Result.Name, result.Definition, result.Result.Status)
+		if len(result.Hooks) > 0 {
+			result.Annotations[AnnotationHooks] = result.Hooks
+		}
+	}
+}
+
+// execute prepares script for single check based on given the request and then executes it
+func (self *Executor) execute(request Request) (Result, error) {
+	check, err := self.substituteCheck(self.definition(request))
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-				status = ws.ExitStatus()
-			} else {
-				status = ExitCodeFailure
+		return self.failedResult(request, check, err.Error()), nil
+	}
+	var outcome execution
+	if factory, ok := nativeChecks[check.Type]; ok {
+		// native checks have no command, so the type is reported instead
+		if check.Command == "" {
+			check.Command = check.Type
+		}
+		outcome = self.runNative(request.Name, check, factory)
+	} else {
+		cmd, err := self.command(check)
+		if err != nil {
+			return self.failedResult(request, check, err.Error()), nil
+		}
+		if len(check.Argv) > 0 {
+			// reported command of checks defined by argv
+			check.Command = strings.Join(check.Argv, " ")
+		}
+		if cmd == nil {
+			cred := self.credential(check)
+			script, err := self.script(check.Command, check.Interpreter, cred)
+			if err != nil {
+				if cred != nil {
+					userName, groupName := self.credentialNames(check)
+					return self.failedResult(request, check, fmt.Sprintf("failed to prepare command for %s:%s: %s", userName, groupName, err)), nil
+				}
+				return Result{}, err
 			}
-		} else {
-			status = ExitCodeFailure
-		}
-	}
-
-	outStr := string(output)
-	result := connector.CheckResult{
-		Client: self.ClientName,
-		Result: connector.Result{
-			Command:  request.Command,
-			Name:     request.Name,
-			Issued:   request.Issued,
-			Executed: start.Unix(),
-			Duration: duration.Seconds(),
-			Output:   outStr,
-			Status:   status,
+			interpreter := self.ShellPath
+			if check.Interpreter != "" {
+				interpreter = check.Interpreter
+			}
+			cmd = exec.Command(interpreter, script)
+		}
+		if check.Stdin {
+			input, err := self.stdin(request, check)
+			if err != nil {
+				return self.failedResult(request, check, fmt.Sprintf("failed to prepare check input: %s", err)), nil
+			}
+			cmd.Stdin = bytes.NewReader(input)
+		}
+
+		outcome = self.run(check, cmd, time.Duration(check.Timeout)*time.Second)
+	}
+	outStr := outcome.stdout.String()
+	if outcome.reason != "" && len(strings.TrimSpace(outStr)) == 0 {
+		outStr = outcome.reason
+	}
+	var metrics []Metric
+	if outcome.stdout.Truncated() && (check.MetricFormat() != "" || self.ParsePerfdata) {
+		// dropped part of the output could contain any of the metrics or split them
+		self.log.Metadata(map[string]interface{}{"check": request.Name, "max_output_size": self.MaxOutputSize})
+		self.log.Warn("Check output was truncated, metrics are not parsed.")
+	} else if format := check.MetricFormat(); format != "" {
+		metrics, err = ParseMetrics(format, outStr, outcome.start.Unix())
+		if err != nil {
+			self.log.Metadata(map[string]interface{}{"check": request.Name, "format": format, "error": err})
+			self.log.Warn("Failed to parse metrics from check output.")
+		}
+	} else if self.ParsePerfdata {
+		outStr, metrics = ParsePerfdata(outStr)
+		for idx := range metrics {
+			metrics[idx].Timestamp = outcome.start.Unix()
+		}
+	}
+	result := Result{
+		CheckResult: connector.CheckResult{
+			Client: self.ClientName,
+			Result: connector.Result{
+				Command:  check.Command,
+				Name:     request.Name,
+				Issued:   request.Issued,
+				Executed: outcome.start.Unix(),
+				Duration: outcome.duration.Seconds(),
+				Output:   outStr,
+				Status:   outcome.status,
+			},
 		},
+		Definition:  check,
+		Metrics:     metrics,
+		Annotations: make(map[string]interface{}),
+	}
+	if outcome.reason != "" {
+		result.Annotations[AnnotationReason] = outcome.reason
+	}
+	if errStr := outcome.stderr.String(); len(errStr) > 0 {
+		result.Annotations[AnnotationStderr] = errStr
+	}
+	if outcome.stdout.Truncated() || outcome.stderr.Truncated() {
+		result.Annotations[AnnotationTruncated] = true
+	}
+	if check.Retries == 0 {
+		self.hooks(&result)
 	}
 
 	self.log.Metadata(map[string]interface{}{
-		"command": request.Command,
-		"status":  status,
+		"command": check.Command,
+		"status":  outcome.status,
+		"reason":  outcome.reason,
 		"output":  outStr,
+		"metrics": len(metrics),
 	})
 	self.log.Debug("Executed check script.")
 	return result, nil
 }
 
+// stopped returns channel which is closed when the executor is stopped
+func (self *Executor) stopped() chan struct{} {
+	self.doneLock.Lock()
+	defer self.doneLock.Unlock()
+	if self.done == nil {
+		self.done = make(chan struct{})
+	}
+	return self.done
+}
+
+//Stop stops retrying of checks. Executions waiting for retry return their last result immediately.
+func (self *Executor) Stop() {
+	done := self.stopped()
+	self.doneLock.Lock()
+	defer self.doneLock.Unlock()
+	select {
+	case <-done:
+	default:
+		close(done)
+	}
+}
+
 func (self *Executor) Clean() {
 	os.Remove(self.TmpBaseDir)
 	self.log.Metadata(map[string]interface{}{"dir": self.TmpBaseDir})
@@ -0,0 +1,236 @@
+package sensu
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+	"github.com/infrawatch/apputils/logging"
+)
+
+// newTestExecutor creates executor with given local checks and temporary directory of the test
+func newTestExecutor(t *testing.T, checks map[string]Check) *Executor {
+	logger, err := logging.NewLogger(logging.ERROR, path.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("failed to create logger: %s", err)
+	}
+	return &Executor{
+		ClientName:       "test",
+		TmpBaseDir:       t.TempDir(),
+		ShellPath:        "/bin/sh",
+		ClientAttributes: map[string]interface{}{"name": "test"},
+		Checks:           checks,
+		log:              logger,
+		scriptCache:      make(map[string]string),
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	executor := Executor{
+		Checks: map[string]Check{
+			"local":  {Command: "check-local.sh", Interval: 10, Labels: map[string]string{"role": "db"}},
+			"argv":   {Argv: []string{"check-argv", "-v"}, Interval: 10},
+			"native": {Type: CheckTypeTCP, Interval: 10},
+			"attrs":  {Labels: map[string]string{"role": "db"}},
+		},
+	}
+	tests := []struct {
+		name    string
+		request Request
+		command string
+		local   bool
+		remote  bool
+	}{
+		{"same command", Request{CheckRequest: connector.CheckRequest{Name: "local", Command: "check-local.sh"}}, "check-local.sh", true, false},
+		{"different command", Request{CheckRequest: connector.CheckRequest{Name: "local", Command: "check-remote.sh"}}, "check-remote.sh", false, true},
+		{"scheduled argv", Request{CheckRequest: connector.CheckRequest{Name: "argv"}}, "", true, false},
+		{"remote argv", Request{CheckRequest: connector.CheckRequest{Name: "argv", Command: "check-remote.sh"}}, "check-remote.sh", false, true},
+		{"scheduled native", Request{CheckRequest: connector.CheckRequest{Name: "native"}}, "", true, false},
+		{"attributes only", Request{CheckRequest: connector.CheckRequest{Name: "attrs", Command: "check-remote.sh"}}, "check-remote.sh", true, true},
+		{"unknown", Request{CheckRequest: connector.CheckRequest{Name: "unknown", Command: "check-remote.sh"}}, "check-remote.sh", false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := executor.definition(test.request)
+			if check.Command != test.command {
+				t.Errorf("expected command %q, got %q", test.command, check.Command)
+			}
+			if local := check.Interval > 0 || len(check.Labels) > 0; local != test.local {
+				t.Errorf("expected local definition to be used: %t, got %t", test.local, local)
+			}
+			if check.remote != test.remote {
+				t.Errorf("expected check to be remote: %t, got %t", test.remote, check.remote)
+			}
+		})
+	}
+}
+
+func TestSandboxed(t *testing.T) {
+	executor := Executor{SandboxMode: SandboxRemote}
+	enabled, disabled := true, false
+	tests := []struct {
+		name      string
+		check     Check
+		sandboxed bool
+	}{
+		{"local", Check{Command: "check-local.sh"}, false},
+		{"remote", Check{Command: "check-remote.sh", remote: true}, true},
+		{"opt out", Check{Command: "check-remote.sh", remote: true, Sandbox: &disabled}, false},
+		{"opt in", Check{Command: "check-local.sh", Sandbox: &enabled}, true},
+	}
+	for _, test := range tests {
+		if sandboxed := executor.sandboxed(test.check); sandboxed != test.sandboxed {
+			t.Errorf("%s: expected sandboxed %t, got %t", test.name, test.sandboxed, sandboxed)
+		}
+	}
+}
+
+func TestRemoteStdin(t *testing.T) {
+	executor := newTestExecutor(t, map[string]Check{})
+	var request Request
+	if err := json.Unmarshal([]byte(`{"name": "remote", "command": "cat", "issued": 1600000000, "stdin": true}`), &request); err != nil {
+		t.Fatalf("failed to parse request: %s", err)
+	}
+	result, err := executor.Execute(request)
+	if err != nil {
+		t.Fatalf("failed to execute check: %s", err)
+	}
+	var input map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(result.Result.Output), &input); err != nil {
+		t.Fatalf("check did not receive JSON input: %q", result.Result.Output)
+	}
+	if input["client"]["name"] != "test" || input["check"]["name"] != "remote" || input["check"]["command"] != "cat" {
+		t.Errorf("unexpected check input: %s", result.Result.Output)
+	}
+
+	request.Stdin = false
+	if result, _ = executor.Execute(request); strings.TrimSpace(result.Result.Output) != "" {
+		t.Errorf("expected no input without stdin attribute, got %q", result.Result.Output)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	executor := newTestExecutor(t, map[string]Check{
+		"slow": {
+			Command: "sleep 10 & sleep 10",
+			Timeout: 1,
+			Hooks:   map[string]Hook{HookCritical: {Command: "echo hook"}},
+		},
+	})
+	start := time.Now()
+	result, err := executor.Execute(Request{CheckRequest: connector.CheckRequest{Name: "slow"}})
+	if err != nil {
+		t.Fatalf("failed to execute check: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("check was not killed after timeout, it ran for %s", elapsed)
+	}
+	if result.Result.Status != ExitCodeFailure || !strings.Contains(result.Result.Output, "timed out") {
+		t.Errorf("expected critical status after timeout, got %d: %q", result.Result.Status, result.Result.Output)
+	}
+	if len(result.Hooks) != 1 || strings.TrimSpace(result.Hooks[0].Output) != "hook" {
+		t.Errorf("expected critical hook to be executed, got %v", result.Hooks)
+	}
+}
+
+func TestTruncatedMetrics(t *testing.T) {
+	executor := newTestExecutor(t, map[string]Check{
+		"perfdata":      {Command: "echo 'OK | a=1 b=2'"},
+		"long perfdata": {Command: "echo 'OK | a=1'; seq 1 100 | sed 's/^/m/;s/$/=1/'"},
+		"graphite":      {Command: "echo 'node.a 1'; seq 1 100 | sed 's/^/node./;s/$/ 1/'", OutputMetricFormat: MetricFormatGraphite},
+	})
+	executor.ParsePerfdata = true
+	executor.MaxOutputSize = 64
+
+	tests := []struct {
+		name      string
+		metrics   int
+		truncated bool
+	}{
+		{"perfdata", 2, false},
+		{"long perfdata", 0, true},
+		{"graphite", 0, true},
+	}
+	for _, test := range tests {
+		result, err := executor.Execute(Request{CheckRequest: connector.CheckRequest{Name: test.name}})
+		if err != nil {
+			t.Fatalf("%s: failed to execute check: %s", test.name, err)
+		}
+		if len(result.Metrics) != test.metrics {
+			t.Errorf("%s: expected %d metrics, got %v", test.name, test.metrics, result.Metrics)
+		}
+		if _, truncated := result.Annotations[AnnotationTruncated]; truncated != test.truncated {
+			t.Errorf("%s: expected truncated %t, got %t", test.name, test.truncated, truncated)
+		}
+	}
+}
+
+func TestSignalStatus(t *testing.T) {
+	executor := newTestExecutor(t, map[string]Check{
+		"shell-status":  {Command: "exit 130"},
+		"shell-killed":  {Command: "kill -TERM $$"},
+		"direct-status": {Argv: []string{"sh", "-c", "exit 130"}},
+		"direct-killed": {Argv: []string{"sh", "-c", "kill -KILL $$"}},
+		"plain-status":  {Command: "exit 200"},
+	})
+	tests := []struct {
+		name   string
+		status int
+		reason string
+	}{
+		{"shell-status", ExitCodeUnknown, "killed by signal: interrupt"},
+		{"shell-killed", ExitCodeUnknown, "killed by signal: terminated"},
+		{"direct-status", 130, ""},
+		{"direct-killed", ExitCodeUnknown, "killed by signal: killed"},
+		{"plain-status", 200, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := executor.Execute(Request{CheckRequest: connector.CheckRequest{Name: test.name}})
+			if err != nil {
+				t.Fatalf("failed to execute check: %s", err)
+			}
+			reason, _ := result.Annotations[AnnotationReason].(string)
+			if result.Result.Status != test.status || reason != test.reason {
+				t.Errorf("expected status %d with reason %q, got %d with %q", test.status, test.reason, result.Result.Status, reason)
+			}
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	counter := path.Join(t.TempDir(), "attempts")
+	executor := newTestExecutor(t, map[string]Check{
+		"flaky":   {Command: fmt.Sprintf("echo >> %s; [ $(wc -l < %s) -ge 3 ]", counter, counter), Retries: 5, Hooks: map[string]Hook{HookOK: {Command: "echo hook"}}},
+		"failing": {Command: "exit 2", Retries: 3, RetryInterval: 60},
+	})
+
+	result, err := executor.Execute(Request{CheckRequest: connector.CheckRequest{Name: "flaky"}})
+	if err != nil {
+		t.Fatalf("failed to execute check: %s", err)
+	}
+	if result.Result.Status != ExitCodeSuccess || result.Annotations[AnnotationAttempts] != 3 || len(result.Hooks) != 1 {
+		t.Errorf("expected success after 3 attempts with hook of final result, got %d after %v attempts with hooks %v",
+			result.Result.Status, result.Annotations[AnnotationAttempts], result.Hooks)
+	}
+
+	// stopped executor does not wait for retries
+	time.AfterFunc(100*time.Millisecond, executor.Stop)
+	start := time.Now()
+	result, err = executor.Execute(Request{CheckRequest: connector.CheckRequest{Name: "failing"}})
+	if err != nil {
+		t.Fatalf("failed to execute check: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retry was not interrupted by stopping the executor, check ran for %s", elapsed)
+	}
+	if result.Result.Status != ExitCodeFailure || result.Annotations[AnnotationAttempts] != 1 {
+		t.Errorf("expected single failed attempt, got %d after %v attempts", result.Result.Status, result.Annotations[AnnotationAttempts])
+	}
+	// stopping the executor again is harmless
+	executor.Stop()
+}
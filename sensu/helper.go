@@ -0,0 +1,68 @@
+package sensu
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Executor helper is the agent binary re-executed with this argv[0]. It applies the check's resource limits
+// and sandbox to itself and then executes the check command, so everything is in place before the command
+// is executed. The helper reports to the executor via JSON message written to file descriptor helperStatusFd.
+const (
+	HelperArg0       = "collectd-sensubility-exec-helper"
+	helperSpecEnvVar = "COLLECTD_SENSUBILITY_HELPER_SPEC"
+	helperPath       = "/proc/self/exe"
+	helperStatusFd   = 3
+)
+
+// helperSpec holds data passed to executor helper
+type helperSpec struct {
+	Limits  Limits       `json:"limits"`
+	Sandbox *SandboxSpec `json:"sandbox,omitempty"`
+	Dir     string       `json:"dir,omitempty"`
+}
+
+// helperStatus is the message sent by executor helper to the executor. Helper sends either failure
+// or, in case it did not replace itself with the command, wait status and CPU time of the command.
+type helperStatus struct {
+	Error      string        `json:"error,omitempty"`
+	Exited     bool          `json:"exited,omitempty"`
+	WaitStatus uint32        `json:"wait_status,omitempty"`
+	CPUTime    time.Duration `json:"cpu_time,omitempty"`
+}
+
+// helperCommand wraps given command to executor helper with given spec. Returned pipe receives
+// status message of the helper and has to be passed to readHelperStatus after the command finishes.
+func helperCommand(cmd *exec.Cmd, spec helperSpec) (*os.File, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Args = append([]string{HelperArg0, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = helperPath
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", helperSpecEnvVar, data))
+	cmd.ExtraFiles = append(cmd.ExtraFiles, writer)
+	return reader, nil
+}
+
+// readHelperStatus reads status message of the helper from given pipe. Returns empty status when
+// the helper succeeded to replace itself with the command.
+func readHelperStatus(cmd *exec.Cmd, reader *os.File) helperStatus {
+	defer reader.Close()
+	for _, file := range cmd.ExtraFiles {
+		file.Close()
+	}
+	var status helperStatus
+	json.NewDecoder(reader).Decode(&status)
+	return status
+}
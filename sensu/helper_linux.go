@@ -0,0 +1,247 @@
+package sensu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"syscall"
+)
+
+const helperSupported = true
+
+// Linux constants missing in syscall package
+const (
+	rlimitNproc       = 6
+	ioprioWhoProcess  = 1
+	ioprioClassShift  = 13
+	ioprioClassRT     = 1
+	ioprioClassBE     = 2
+	ioprioClassIdle   = 3
+	prioProcessThread = 0
+)
+
+// devices bind mounted to sandbox
+var sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom"}
+
+func setRlimit(resource int, value uint64, hard uint64) error {
+	if value == 0 {
+		return nil
+	}
+	return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: value, Max: hard})
+}
+
+// applyLimits sets limits of the calling thread, so it has to be locked to its OS thread
+func applyLimits(limits Limits) error {
+	// hard CPU limit is one second higher, so the process gets SIGXCPU first
+	if err := setRlimit(syscall.RLIMIT_CPU, limits.CPU, limits.CPU+1); err != nil {
+		return fmt.Errorf("failed to set CPU limit: %s", err)
+	}
+	if err := setRlimit(syscall.RLIMIT_AS, limits.AddressSpace, limits.AddressSpace); err != nil {
+		return fmt.Errorf("failed to set address space limit: %s", err)
+	}
+	if err := setRlimit(syscall.RLIMIT_NOFILE, limits.OpenFiles, limits.OpenFiles); err != nil {
+		return fmt.Errorf("failed to set open files limit: %s", err)
+	}
+	if err := setRlimit(rlimitNproc, limits.Processes, limits.Processes); err != nil {
+		return fmt.Errorf("failed to set process count limit: %s", err)
+	}
+	if limits.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, prioProcessThread, limits.Nice); err != nil {
+			return fmt.Errorf("failed to set nice value: %s", err)
+		}
+	}
+	if limits.IOClass != "" {
+		class := map[string]int{IOClassRealtime: ioprioClassRT, IOClassBestEffort: ioprioClassBE, IOClassIdle: ioprioClassIdle}[limits.IOClass]
+		prio := class<<ioprioClassShift | limits.IOPriority
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, 0, uintptr(prio)); errno != 0 {
+			return fmt.Errorf("failed to set I/O scheduling: %s", errno)
+		}
+	}
+	return nil
+}
+
+// sandboxAttrs sets attributes of the helper process so that it starts in new namespaces
+func sandboxAttrs(cmd *exec.Cmd, spec *SandboxSpec) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// credentials are applied by the helper after the sandbox is set up
+	cmd.SysProcAttr.Credential = nil
+	cmd.SysProcAttr.Cloneflags = syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC
+	if spec.Network {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+}
+
+// bindReadOnly bind mounts given path to the same path under given root in read-only mode.
+// Mounts under the path are not bound, so nothing writable leaks to the sandbox. Symbolic links
+// are recreated instead and missing paths are skipped.
+func bindReadOnly(root string, source string) error {
+	info, err := os.Lstat(source)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	target := path.Join(root, source)
+	if err := os.MkdirAll(path.Dir(target), 0755); err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(source)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	case info.IsDir():
+		err = os.MkdirAll(target, 0755)
+	default:
+		err = ioutil.WriteFile(target, []byte{}, 0644)
+	}
+	if err != nil {
+		return err
+	}
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("failed to bind %s: %s", source, err)
+	}
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY | syscall.MS_NOSUID)
+	if err := syscall.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("failed to remount %s read-only: %s", source, err)
+	}
+	return nil
+}
+
+// setupSandbox builds root filesystem of the sandbox and switches to it
+func setupSandbox(spec *SandboxSpec) error {
+	// changes of mounts in the new namespace must not propagate to the host
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %s", err)
+	}
+	if err := os.MkdirAll(spec.Root, 0700); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", spec.Root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("failed to mount sandbox root: %s", err)
+	}
+	for _, dir := range []string{"proc", "dev", "tmp", ".oldroot"} {
+		if err := os.MkdirAll(path.Join(spec.Root, dir), 0755); err != nil {
+			return err
+		}
+	}
+	if err := syscall.Mount("tmpfs", path.Join(spec.Root, "tmp"), "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("failed to mount /tmp: %s", err)
+	}
+	// binds are done after /tmp is mounted, so they can be placed there too
+	for _, bind := range spec.Binds {
+		if err := bindReadOnly(spec.Root, bind); err != nil {
+			return err
+		}
+	}
+	if err := syscall.Mount("proc", path.Join(spec.Root, "proc"), "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("failed to mount /proc: %s", err)
+	}
+	if err := syscall.Mount("tmpfs", path.Join(spec.Root, "dev"), "tmpfs", syscall.MS_NOSUID|syscall.MS_NOEXEC, "mode=0755"); err != nil {
+		return fmt.Errorf("failed to mount /dev: %s", err)
+	}
+	for _, device := range sandboxDevices {
+		if _, err := os.Stat(device); err != nil {
+			continue
+		}
+		target := path.Join(spec.Root, device)
+		if err := ioutil.WriteFile(target, []byte{}, 0666); err != nil {
+			return err
+		}
+		if err := syscall.Mount(device, target, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("failed to bind %s: %s", device, err)
+		}
+	}
+
+	if err := syscall.PivotRoot(spec.Root, path.Join(spec.Root, ".oldroot")); err != nil {
+		return fmt.Errorf("failed to switch root: %s", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	if err := syscall.Unmount("/.oldroot", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("failed to detach host root: %s", err)
+	}
+	os.Remove("/.oldroot")
+	// the sandbox root itself has to be read-only as well
+	return syscall.Mount("", "/", "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, "")
+}
+
+// runSandboxed sets up sandbox and runs the command in it. The helper is init process of the sandbox,
+// so it cannot replace itself with the command and waits for it instead.
+func runSandboxed(status *os.File, spec helperSpec, command string, args []string) {
+	if err := setupSandbox(spec.Sandbox); err != nil {
+		helperFail(status, err)
+	}
+	if err := applyLimits(spec.Limits); err != nil {
+		helperFail(status, err)
+	}
+	cmdPath, err := exec.LookPath(command)
+	if err != nil {
+		helperFail(status, err)
+	}
+	cmd := exec.Command(cmdPath, args...)
+	cmd.Args[0] = command
+	// working directory of the helper is the sandbox root now, so the check's one has to be entered again
+	cmd.Dir = spec.Dir
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if spec.Sandbox.Credential != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: spec.Sandbox.Credential}
+	}
+	if err := cmd.Start(); err != nil {
+		helperFail(status, fmt.Errorf("failed to execute %s: %s", cmdPath, err))
+	}
+	cmd.Wait()
+	json.NewEncoder(status).Encode(helperStatus{
+		Exited:     true,
+		WaitStatus: uint32(cmd.ProcessState.Sys().(syscall.WaitStatus)),
+		CPUTime:    cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime(),
+	})
+	os.Exit(0)
+}
+
+// helperFail reports failure to the executor and exits
+func helperFail(status *os.File, err error) {
+	json.NewEncoder(status).Encode(helperStatus{Error: err.Error()})
+	fmt.Fprintln(os.Stderr, err.Error())
+	os.Exit(ExitCodeNotExecutable)
+}
+
+//RunHelper runs executor helper when the process was started as one, otherwise it returns immediately.
+//Helper applies resource limits and sandbox of the check and then executes the check command.
+func RunHelper() {
+	if len(os.Args) < 2 || os.Args[0] != HelperArg0 {
+		return
+	}
+	// nice value and I/O priority are per thread, so the thread applying them has to execute the command
+	runtime.LockOSThread()
+	syscall.CloseOnExec(helperStatusFd)
+	status := os.NewFile(helperStatusFd, "status")
+
+	var spec helperSpec
+	if err := json.Unmarshal([]byte(os.Getenv(helperSpecEnvVar)), &spec); err != nil {
+		helperFail(status, fmt.Errorf("failed to parse helper spec: %s", err))
+	}
+	os.Unsetenv(helperSpecEnvVar)
+
+	if spec.Sandbox != nil {
+		runSandboxed(status, spec, os.Args[1], os.Args[2:])
+	}
+	if err := applyLimits(spec.Limits); err != nil {
+		helperFail(status, err)
+	}
+	cmdPath, err := exec.LookPath(os.Args[1])
+	if err != nil {
+		helperFail(status, err)
+	}
+	err = syscall.Exec(cmdPath, os.Args[1:], os.Environ())
+	helperFail(status, fmt.Errorf("failed to execute %s: %s", cmdPath, err))
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package sensu
+
+import (
+	"os/exec"
+)
+
+const helperSupported = false
+
+// sandboxAttrs does nothing as sandbox is supported only on Linux
+func sandboxAttrs(cmd *exec.Cmd, spec *SandboxSpec) {}
+
+//RunHelper does nothing as executor helper is supported only on Linux
+func RunHelper() {}
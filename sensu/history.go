@@ -0,0 +1,145 @@
+package sensu
+
+import (
+	"fmt"
+	"sync"
+)
+
+//HistorySize is number of statuses kept per check, same as in Sensu
+const HistorySize = 21
+
+// Result annotations describing state of the check
+const (
+	// AnnotationHistory holds last statuses of the check, the oldest first
+	AnnotationHistory = "history"
+	// AnnotationOccurrences holds number of consecutive results with the same status
+	AnnotationOccurrences = "occurrences"
+	// AnnotationFlapping is set when the check is flapping
+	AnnotationFlapping = "flapping"
+	// AnnotationStateChange holds weighted percentage of state changes in the history
+	AnnotationStateChange = "total_state_change"
+)
+
+//CheckState holds history of single check and state derived from it
+type CheckState struct {
+	History          []int `json:"history"`
+	Occurrences      int   `json:"occurrences"`
+	Flapping         bool  `json:"flapping"`
+	TotalStateChange int   `json:"total_state_change"`
+}
+
+//History keeps state of checks by name and detects flapping checks. Thresholds of flap detection
+//given to the history apply to checks without their own thresholds, zero thresholds disable it.
+type History struct {
+	LowFlapThreshold  int
+	HighFlapThreshold int
+	lock              sync.Mutex
+	states            map[string]*CheckState
+}
+
+//NewHistory creates empty history with given default flap detection thresholds
+func NewHistory(low int, high int) *History {
+	return &History{
+		LowFlapThreshold:  low,
+		HighFlapThreshold: high,
+		states:            make(map[string]*CheckState),
+	}
+}
+
+//Restore sets state of given check, so that history of the check continues from state stored
+//by previous run of the agent
+func (self *History) Restore(name string, state CheckState) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if len(state.History) > HistorySize {
+		state.History = state.History[len(state.History)-HistorySize:]
+	}
+	state.History = append([]int{}, state.History...)
+	self.states[name] = &state
+}
+
+//ValidateFlapThresholds checks that flap detection thresholds are percentages and the low one
+//is not higher than the high one. Zero high threshold disables flap detection.
+func ValidateFlapThresholds(low int, high int) error {
+	if low < 0 || high < 0 || low > 100 || high > 100 {
+		return fmt.Errorf("flap thresholds have to be percentages")
+	}
+	if high > 0 && low > high {
+		return fmt.Errorf("low flap threshold is higher than high flap threshold")
+	}
+	if high == 0 && low > 0 {
+		return fmt.Errorf("missing high flap threshold")
+	}
+	return nil
+}
+
+// totalStateChange computes weighted percentage of state changes in given history the same way as Sensu does.
+// Recent changes weight more than old ones. Zero is returned until the history is full.
+func totalStateChange(history []int) int {
+	if len(history) < HistorySize {
+		return 0
+	}
+	stateChanges := 0.0
+	weight := 0.8
+	previous := history[0]
+	for _, status := range history {
+		if status != previous {
+			stateChanges += weight
+		}
+		weight += 0.02
+		previous = status
+	}
+	return int(stateChanges / float64(HistorySize-1) * 100)
+}
+
+//Record adds status of given result to the history of its check and stores the resulting check state
+//to the result, including its annotations
+func (self *History) Record(result *Result) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	state, ok := self.states[result.Result.Name]
+	if !ok {
+		state = &CheckState{}
+		self.states[result.Result.Name] = state
+	}
+
+	status := result.Result.Status
+	if len(state.History) > 0 && state.History[len(state.History)-1] == status {
+		state.Occurrences++
+	} else {
+		state.Occurrences = 1
+	}
+	state.History = append(state.History, status)
+	if len(state.History) > HistorySize {
+		state.History = state.History[len(state.History)-HistorySize:]
+	}
+
+	low, high := self.LowFlapThreshold, self.HighFlapThreshold
+	if result.Definition.LowFlapThreshold > 0 || result.Definition.HighFlapThreshold > 0 {
+		low, high = result.Definition.LowFlapThreshold, result.Definition.HighFlapThreshold
+	}
+	state.TotalStateChange = totalStateChange(state.History)
+	switch {
+	case high == 0:
+		state.Flapping = false
+	case state.Flapping:
+		// flapping stops only after state changes drop below the low threshold
+		state.Flapping = state.TotalStateChange > low
+	default:
+		state.Flapping = state.TotalStateChange >= high
+	}
+
+	result.State = CheckState{
+		History:          append([]int{}, state.History...),
+		Occurrences:      state.Occurrences,
+		Flapping:         state.Flapping,
+		TotalStateChange: state.TotalStateChange,
+	}
+	if result.Annotations == nil {
+		result.Annotations = make(map[string]interface{})
+	}
+	result.Annotations[AnnotationHistory] = result.State.History
+	result.Annotations[AnnotationOccurrences] = result.State.Occurrences
+	result.Annotations[AnnotationFlapping] = result.State.Flapping
+	result.Annotations[AnnotationStateChange] = result.State.TotalStateChange
+}
@@ -0,0 +1,108 @@
+package sensu
+
+import (
+	"testing"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+)
+
+// repeat returns history consisting of given statuses repeated to the full history size
+func repeat(statuses ...int) []int {
+	history := make([]int, HistorySize)
+	for idx := range history {
+		history[idx] = statuses[idx%len(statuses)]
+	}
+	return history
+}
+
+func TestTotalStateChange(t *testing.T) {
+	changeAt := func(idx int) []int {
+		history := repeat(0)
+		for i := idx; i < HistorySize; i++ {
+			history[i] = 2
+		}
+		return history
+	}
+	tests := []struct {
+		name    string
+		history []int
+		change  int
+	}{
+		{"not full", []int{0, 2, 0, 2}, 0},
+		{"stable", repeat(0), 0},
+		// weights of changes sum up to more than 100 % the same way as in Sensu
+		{"alternating", repeat(0, 2), 101},
+		{"oldest change", changeAt(1), 4},
+		{"newest change", changeAt(HistorySize - 1), 6},
+		{"every third", repeat(0, 0, 2), 66},
+	}
+	for _, test := range tests {
+		if change := totalStateChange(test.history); change != test.change {
+			t.Errorf("%s: expected total state change %d, got %d", test.name, test.change, change)
+		}
+	}
+}
+
+func TestHistoryFlapping(t *testing.T) {
+	history := NewHistory(20, 40)
+	record := func(status int, definition Check) Result {
+		result := Result{CheckResult: connector.CheckResult{Result: connector.Result{Name: "check", Status: status}}, Definition: definition}
+		history.Record(&result)
+		return result
+	}
+
+	var result Result
+	for _, status := range repeat(0, 2) {
+		result = record(status, Check{})
+	}
+	if !result.State.Flapping || result.State.TotalStateChange != 101 || len(result.State.History) != HistorySize {
+		t.Errorf("expected alternating check to flap, got %+v", result.State)
+	}
+	// flapping stops only once state changes drop to the low threshold
+	for i := 0; i < 15; i++ {
+		result = record(0, Check{})
+	}
+	if !result.State.Flapping || result.State.TotalStateChange != 21 {
+		t.Errorf("expected check to keep flapping above low threshold, got %+v", result.State)
+	}
+	if result = record(0, Check{}); result.State.Flapping || result.State.TotalStateChange != 17 {
+		t.Errorf("expected check to stop flapping below low threshold, got %+v", result.State)
+	}
+	if result.State.Occurrences != 17 || result.Annotations[AnnotationOccurrences] != 17 || result.Annotations[AnnotationFlapping] != false {
+		t.Errorf("unexpected annotations of stable check: %v", result.Annotations)
+	}
+
+	// thresholds of the check override the default ones
+	for _, status := range []int{2, 0, 2, 0} {
+		result = record(status, Check{LowFlapThreshold: 5, HighFlapThreshold: 10})
+	}
+	if !result.State.Flapping {
+		t.Errorf("expected check thresholds to be applied, got %+v", result.State)
+	}
+	history.Restore("check", CheckState{History: repeat(0), Occurrences: 30})
+	if result = record(0, Check{}); result.State.Flapping || result.State.Occurrences != 31 || len(result.State.History) != HistorySize {
+		t.Errorf("expected history to continue from restored state, got %+v", result.State)
+	}
+}
+
+func TestValidateFlapThresholds(t *testing.T) {
+	tests := []struct {
+		low   int
+		high  int
+		valid bool
+	}{
+		{0, 0, true},
+		{20, 40, true},
+		{0, 40, true},
+		{40, 40, true},
+		{40, 20, false},
+		{20, 0, false},
+		{-1, 40, false},
+		{20, 101, false},
+	}
+	for _, test := range tests {
+		if err := ValidateFlapThresholds(test.low, test.high); (err == nil) != test.valid {
+			t.Errorf("thresholds %d and %d: expected valid %t, got error %v", test.low, test.high, test.valid, err)
+		}
+	}
+}
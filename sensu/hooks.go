@@ -0,0 +1,117 @@
+package sensu
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Statuses by which check hooks are keyed. Hooks can be keyed by exact exit status too.
+const (
+	HookOK       = "ok"
+	HookWarning  = "warning"
+	HookCritical = "critical"
+	HookUnknown  = "unknown"
+	HookNonZero  = "non-zero"
+)
+
+//DefaultHookTimeout is the timeout of hooks without one in seconds
+const DefaultHookTimeout = 60
+
+//Hook is a command executed after the check returned particular status
+type Hook struct {
+	Command string `json:"command"`
+	Timeout int    `json:"timeout"`
+}
+
+//HookResult holds outcome of single hook execution
+type HookResult struct {
+	Name     string  `json:"name"`
+	Command  string  `json:"command"`
+	Executed int64   `json:"executed"`
+	Duration float64 `json:"duration"`
+	Output   string  `json:"output"`
+	Status   int     `json:"status"`
+}
+
+// hookKeys returns keys of hooks which apply to given check status in execution order
+func hookKeys(status int) []string {
+	keys := []string{strconv.Itoa(status)}
+	switch status {
+	case ExitCodeSuccess:
+		return append(keys, HookOK)
+	case ExitCodeWarning:
+		keys = append(keys, HookWarning)
+	case ExitCodeFailure:
+		keys = append(keys, HookCritical)
+	case ExitCodeUnknown:
+		keys = append(keys, HookUnknown)
+	}
+	return append(keys, HookNonZero)
+}
+
+// validateHooks checks validity of hook definitions
+func validateHooks(hooks map[string]Hook) error {
+	keys := make([]string, 0, len(hooks))
+	for key := range hooks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch key {
+		case HookOK, HookWarning, HookCritical, HookUnknown, HookNonZero:
+		default:
+			if status, err := strconv.Atoi(key); err != nil || status < 0 || status > 255 {
+				return fmt.Errorf("invalid hook status: %s", key)
+			}
+		}
+		if strings.TrimSpace(hooks[key].Command) == "" {
+			return fmt.Errorf("missing command of hook %s", key)
+		}
+		if hooks[key].Timeout < 0 {
+			return fmt.Errorf("invalid timeout of hook %s: %d", key, hooks[key].Timeout)
+		}
+	}
+	return nil
+}
+
+// runHooks executes hooks of the check which apply to given status. Hooks are executed the same way
+// as the check itself, so they run under the same credentials, limits and sandbox.
+func (self *Executor) runHooks(name string, check Check, status int) []HookResult {
+	results := []HookResult{}
+	for _, key := range hookKeys(status) {
+		hook, ok := check.Hooks[key]
+		if !ok {
+			continue
+		}
+		result := HookResult{Name: key, Command: hook.Command}
+		script, err := self.script(hook.Command, "", self.credential(check))
+		if err != nil {
+			result.Executed = time.Now().Unix()
+			result.Output = err.Error()
+			result.Status = ExitCodeUnknown
+			results = append(results, result)
+			continue
+		}
+		timeout := hook.Timeout
+		if timeout == 0 {
+			timeout = DefaultHookTimeout
+		}
+		outcome := self.run(check, exec.Command(self.ShellPath, script), time.Duration(timeout)*time.Second)
+		result.Executed = outcome.start.Unix()
+		result.Duration = outcome.duration.Seconds()
+		result.Output = outcome.stdout.String() + outcome.stderr.String()
+		if outcome.reason != "" && len(strings.TrimSpace(result.Output)) == 0 {
+			result.Output = outcome.reason
+		}
+		result.Status = outcome.status
+
+		self.log.Metadata(map[string]interface{}{"check": name, "hook": key, "status": result.Status})
+		self.log.Debug("Executed check hook.")
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,74 @@
+package sensu
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+//I/O scheduling classes
+const (
+	IOClassRealtime   = "realtime"
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+//Limits holds resource limits of the check process. Zero values mean no limit.
+type Limits struct {
+	CPU          uint64 `json:"cpu"`
+	AddressSpace uint64 `json:"address_space"`
+	OpenFiles    uint64 `json:"open_files"`
+	Processes    uint64 `json:"processes"`
+	Nice         int    `json:"nice"`
+	IOClass      string `json:"ionice_class"`
+	IOPriority   int    `json:"ionice_priority"`
+}
+
+//IsZero returns true if no limit is set
+func (lim Limits) IsZero() bool {
+	return lim == Limits{}
+}
+
+//Validate checks validity of the limit values
+func (lim Limits) Validate() error {
+	if lim.IsZero() {
+		return nil
+	}
+	if !helperSupported {
+		return fmt.Errorf("resource limits are not supported on this platform")
+	}
+	if lim.Nice < -20 || lim.Nice > 19 {
+		return fmt.Errorf("nice value %d is out of range -20..19", lim.Nice)
+	}
+	switch lim.IOClass {
+	case "", IOClassRealtime, IOClassBestEffort, IOClassIdle:
+	default:
+		return fmt.Errorf("unknown ionice class: %s", lim.IOClass)
+	}
+	if lim.IOPriority < 0 || lim.IOPriority > 7 {
+		return fmt.Errorf("ionice priority %d is out of range 0..7", lim.IOPriority)
+	}
+	return nil
+}
+
+// limitReason explains termination of the command in case it was killed because of exceeding given limits.
+// Given CPU time has to include CPU time of children of the command, which are limited too.
+func limitReason(limits Limits, ws syscall.WaitStatus, shell bool, cpuTime time.Duration) string {
+	signal, ok := terminatingSignal(ws, shell)
+	if !ok {
+		return ""
+	}
+	switch signal {
+	case syscall.SIGXCPU:
+		return fmt.Sprintf("killed for exceeding CPU time limit of %d seconds", limits.CPU)
+	case syscall.SIGKILL:
+		if limits.CPU > 0 && uint64(cpuTime.Seconds()) >= limits.CPU {
+			return fmt.Sprintf("killed for exceeding CPU time limit of %d seconds", limits.CPU)
+		}
+	case syscall.SIGSEGV, syscall.SIGABRT, syscall.SIGBUS:
+		if limits.AddressSpace > 0 {
+			return fmt.Sprintf("killed by signal %s, possibly for exceeding address space limit of %d bytes", signal, limits.AddressSpace)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,254 @@
+package sensu
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//Check types
+const (
+	CheckTypeStandard = "standard"
+	CheckTypeMetric   = "metric"
+)
+
+//Output metric formats as used in Sensu Go check definitions
+const (
+	MetricFormatNagios   = "nagios_perfdata"
+	MetricFormatGraphite = "graphite_plaintext"
+	MetricFormatInflux   = "influxdb_line"
+	MetricFormatOpenTSDB = "opentsdb_line"
+)
+
+//MetricFormats lists supported output metric formats
+var MetricFormats = []string{MetricFormatNagios, MetricFormatGraphite, MetricFormatInflux, MetricFormatOpenTSDB}
+
+// parseValue parses value of metric sample. Values which are not finite cannot be sent in JSON,
+// so they are refused.
+func parseValue(raw string) (float64, error) {
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("value is not finite: %s", raw)
+	}
+	return value, nil
+}
+
+func parseTimestamp(value string, timestamp int64) (int64, error) {
+	if value == "" {
+		return timestamp, nil
+	}
+	ts, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	// convert milli, micro and nanoseconds to seconds
+	for ts > 9999999999 {
+		ts /= 1000
+	}
+	return ts, nil
+}
+
+// parseGraphite parses lines in format "metric.path[;tag=value...] value [timestamp]"
+func parseGraphite(line string, timestamp int64) ([]Metric, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("invalid graphite line: %s", line)
+	}
+	value, err := parseValue(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	ts := timestamp
+	if len(fields) == 3 {
+		if ts, err = parseTimestamp(fields[2], timestamp); err != nil {
+			return nil, err
+		}
+	}
+	parts := strings.Split(fields[0], ";")
+	metric := Metric{Name: parts[0], Value: value, Timestamp: ts, Tags: make(map[string]string)}
+	for _, tag := range parts[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid graphite tag: %s", tag)
+		}
+		metric.Tags[kv[0]] = kv[1]
+	}
+	return []Metric{metric}, nil
+}
+
+// splitEscaped splits string by given separator ignoring separators escaped by backslash
+// and separators inside double quotes. Escaping backslashes are kept, so that the parts can be split further.
+func splitEscaped(input string, separator byte) []string {
+	parts := []string{}
+	var part strings.Builder
+	quoted := false
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case c == '\\' && i+1 < len(input):
+			part.WriteByte(c)
+			i++
+			part.WriteByte(input[i])
+		case c == '"':
+			quoted = !quoted
+			part.WriteByte(c)
+		case c == separator && !quoted:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(c)
+		}
+	}
+	return append(parts, part.String())
+}
+
+// unescape removes escaping backslashes from given string
+func unescape(input string) string {
+	var output strings.Builder
+	for i := 0; i < len(input); i++ {
+		if input[i] == '\\' && i+1 < len(input) {
+			i++
+		}
+		output.WriteByte(input[i])
+	}
+	return output.String()
+}
+
+// parseInflux parses lines in format "measurement[,tag=value...] field=value[,field=value...] [timestamp]".
+// Each numeric field results in single metric named "measurement.field". String fields are ignored.
+func parseInflux(line string, timestamp int64) ([]Metric, error) {
+	sections := []string{}
+	for _, section := range splitEscaped(line, ' ') {
+		if section != "" {
+			sections = append(sections, section)
+		}
+	}
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, fmt.Errorf("invalid influxdb line: %s", line)
+	}
+	ts := timestamp
+	if len(sections) == 3 {
+		var err error
+		if ts, err = parseTimestamp(sections[2], timestamp); err != nil {
+			return nil, err
+		}
+	}
+	series := splitEscaped(sections[0], ',')
+	tags := make(map[string]string)
+	for _, tag := range series[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid influxdb tag: %s", tag)
+		}
+		tags[unescape(kv[0])] = unescape(kv[1])
+	}
+
+	metrics := []Metric{}
+	for _, field := range splitEscaped(sections[1], ',') {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid influxdb field: %s", field)
+		}
+		var value float64
+		var err error
+		switch raw := kv[1]; {
+		case strings.HasPrefix(raw, "\""):
+			continue
+		case raw == "t" || raw == "T" || raw == "true" || raw == "True" || raw == "TRUE":
+			value = 1
+		case raw == "f" || raw == "F" || raw == "false" || raw == "False" || raw == "FALSE":
+			value = 0
+		case strings.HasSuffix(raw, "i") || strings.HasSuffix(raw, "u"):
+			value, err = parseValue(raw[:len(raw)-1])
+		default:
+			value, err = parseValue(raw)
+		}
+		if err != nil {
+			return nil, err
+		}
+		metricTags := make(map[string]string)
+		for key, val := range tags {
+			metricTags[key] = val
+		}
+		metrics = append(metrics, Metric{
+			Name:      fmt.Sprintf("%s.%s", unescape(series[0]), unescape(kv[0])),
+			Value:     value,
+			Timestamp: ts,
+			Tags:      metricTags,
+		})
+	}
+	return metrics, nil
+}
+
+// parseOpenTSDB parses lines in format "[put ]metric timestamp value [tag=value...]"
+func parseOpenTSDB(line string, timestamp int64) ([]Metric, error) {
+	fields := strings.Fields(line)
+	if len(fields) > 0 && fields[0] == "put" {
+		fields = fields[1:]
+	}
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("invalid opentsdb line: %s", line)
+	}
+	ts, err := parseTimestamp(fields[1], timestamp)
+	if err != nil {
+		return nil, err
+	}
+	value, err := parseValue(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	metric := Metric{Name: fields[0], Value: value, Timestamp: ts, Tags: make(map[string]string)}
+	for _, tag := range fields[3:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid opentsdb tag: %s", tag)
+		}
+		metric.Tags[kv[0]] = kv[1]
+	}
+	return []Metric{metric}, nil
+}
+
+//ParseMetrics parses check output in given format to metrics. Given timestamp is used for samples
+//without one. Lines which cannot be parsed are skipped and reported in returned error.
+func ParseMetrics(format string, output string, timestamp int64) ([]Metric, error) {
+	var parser func(string, int64) ([]Metric, error)
+	switch format {
+	case MetricFormatNagios:
+		_, metrics := ParsePerfdata(output)
+		for idx := range metrics {
+			metrics[idx].Timestamp = timestamp
+		}
+		return metrics, nil
+	case MetricFormatGraphite:
+		parser = parseGraphite
+	case MetricFormatInflux:
+		parser = parseInflux
+	case MetricFormatOpenTSDB:
+		parser = parseOpenTSDB
+	default:
+		return nil, fmt.Errorf("unknown output metric format: %s", format)
+	}
+
+	metrics := []Metric{}
+	invalid := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parsed, err := parser(line, timestamp)
+		if err != nil {
+			invalid = append(invalid, line)
+			continue
+		}
+		metrics = append(metrics, parsed...)
+	}
+	if len(invalid) > 0 {
+		return metrics, fmt.Errorf("failed to parse %d line(s) of output: %s", len(invalid), strings.Join(invalid, "; "))
+	}
+	return metrics, nil
+}
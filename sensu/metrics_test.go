@@ -0,0 +1,121 @@
+package sensu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMetrics(t *testing.T) {
+	const now = 1600000000
+	tests := []struct {
+		name    string
+		format  string
+		output  string
+		metrics []Metric
+		invalid bool
+	}{
+		{
+			name:   "nagios",
+			format: MetricFormatNagios,
+			output: "OK | time=0.5s;1;2",
+			metrics: []Metric{
+				{Name: "time", Value: 0.5, Timestamp: now, Unit: "s", Warning: "1", Critical: "2"},
+			},
+		},
+		{
+			name:   "graphite",
+			format: MetricFormatGraphite,
+			output: "# comment\nnode.cpu.load 0.5 1500000000\nnode.mem;host=a;dc=b 42\n\nnode.ms 1 1500000000123",
+			metrics: []Metric{
+				{Name: "node.cpu.load", Value: 0.5, Timestamp: 1500000000, Tags: map[string]string{}},
+				{Name: "node.mem", Value: 42, Timestamp: now, Tags: map[string]string{"host": "a", "dc": "b"}},
+				{Name: "node.ms", Value: 1, Timestamp: 1500000000, Tags: map[string]string{}},
+			},
+		},
+		{
+			name:    "graphite invalid",
+			format:  MetricFormatGraphite,
+			output:  "node.cpu 1\nnode.bad value\nnode.tag;x 1\ntoo many fields here",
+			metrics: []Metric{{Name: "node.cpu", Value: 1, Timestamp: now, Tags: map[string]string{}}},
+			invalid: true,
+		},
+		{
+			name:   "influx",
+			format: MetricFormatInflux,
+			output: "cpu,host=a,region=b usage=0.5,count=3i,ok=true,msg=\"x, y\" 1500000000000000000\nmem free=10u",
+			metrics: []Metric{
+				{Name: "cpu.usage", Value: 0.5, Timestamp: 1500000000, Tags: map[string]string{"host": "a", "region": "b"}},
+				{Name: "cpu.count", Value: 3, Timestamp: 1500000000, Tags: map[string]string{"host": "a", "region": "b"}},
+				{Name: "cpu.ok", Value: 1, Timestamp: 1500000000, Tags: map[string]string{"host": "a", "region": "b"}},
+				{Name: "mem.free", Value: 10, Timestamp: now, Tags: map[string]string{}},
+			},
+		},
+		{
+			name:   "influx escaping",
+			format: MetricFormatInflux,
+			output: `disk\ io,path=/var\ lib,dev=sd\,a read\ ops=1`,
+			metrics: []Metric{
+				{Name: "disk io.read ops", Value: 1, Timestamp: now, Tags: map[string]string{"path": "/var lib", "dev": "sd,a"}},
+			},
+		},
+		{
+			name:    "influx invalid",
+			format:  MetricFormatInflux,
+			output:  "cpu\ncpu,host usage=1\ncpu usage=x\ncpu usage",
+			metrics: []Metric{},
+			invalid: true,
+		},
+		{
+			name:    "graphite not finite",
+			format:  MetricFormatGraphite,
+			output:  "node.a NaN\nnode.b +Inf\nnode.c -inf\nnode.d 1",
+			metrics: []Metric{{Name: "node.d", Value: 1, Timestamp: now, Tags: map[string]string{}}},
+			invalid: true,
+		},
+		{
+			name:    "influx not finite",
+			format:  MetricFormatInflux,
+			output:  "cpu usage=NaN\ncpu usage=Inf\ncpu usage=1",
+			metrics: []Metric{{Name: "cpu.usage", Value: 1, Timestamp: now, Tags: map[string]string{}}},
+			invalid: true,
+		},
+		{
+			name:    "opentsdb not finite",
+			format:  MetricFormatOpenTSDB,
+			output:  "sys.cpu 1500000000 NaN\nsys.mem 1500000000 Infinity",
+			metrics: []Metric{},
+			invalid: true,
+		},
+		{
+			name:   "opentsdb",
+			format: MetricFormatOpenTSDB,
+			output: "put sys.cpu 1500000000 0.5 host=a\nsys.mem 1500000000000 42",
+			metrics: []Metric{
+				{Name: "sys.cpu", Value: 0.5, Timestamp: 1500000000, Tags: map[string]string{"host": "a"}},
+				{Name: "sys.mem", Value: 42, Timestamp: 1500000000, Tags: map[string]string{}},
+			},
+		},
+		{
+			name:    "opentsdb invalid",
+			format:  MetricFormatOpenTSDB,
+			output:  "put sys.cpu 1500000000\nsys.cpu now 1\nsys.cpu 1500000000 1 host",
+			metrics: []Metric{},
+			invalid: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metrics, err := ParseMetrics(test.format, test.output, now)
+			if (err != nil) != test.invalid {
+				t.Errorf("expected invalid lines to be reported: %t, got error %v", test.invalid, err)
+			}
+			if !reflect.DeepEqual(metrics, test.metrics) {
+				t.Errorf("expected metrics %+v, got %+v", test.metrics, metrics)
+			}
+		})
+	}
+
+	if _, err := ParseMetrics("unknown", "", now); err == nil {
+		t.Errorf("expected unknown format to be rejected")
+	}
+}
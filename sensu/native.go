@@ -0,0 +1,146 @@
+package sensu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//DefaultNativeTimeout is the timeout of native checks in seconds used when the check has none
+const DefaultNativeTimeout = 10
+
+//NativeCheck is a check implemented in the agent itself, so no command is executed for it. It returns
+//check status and output in the same format as Nagios plugins do.
+type NativeCheck interface {
+	Run(ctx context.Context) (int, string)
+}
+
+//NativeCheckFactory creates native check from parameters given in check definition
+type NativeCheckFactory func(params json.RawMessage) (NativeCheck, error)
+
+var nativeChecks = make(map[string]NativeCheckFactory)
+
+// namedCheck is native check which keeps state between runs. Name of the check is given to it before
+// it is run, so that checks with the same parameters do not share the state.
+type namedCheck interface {
+	setName(name string)
+}
+
+//RegisterNativeCheck registers native check type selectable by type attribute of check definition
+func RegisterNativeCheck(checkType string, factory NativeCheckFactory) {
+	if checkType == CheckTypeStandard || checkType == CheckTypeMetric {
+		panic(fmt.Sprintf("check type %s is reserved", checkType))
+	}
+	nativeChecks[checkType] = factory
+}
+
+//NativeCheckTypes returns sorted list of registered native check types
+func NativeCheckTypes() []string {
+	types := make([]string, 0, len(nativeChecks))
+	for checkType := range nativeChecks {
+		types = append(types, checkType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// decodeParams decodes parameters of native check to given struct. Unknown parameters are refused.
+func decodeParams(params json.RawMessage, target interface{}) error {
+	if len(bytes.TrimSpace(params)) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("invalid parameters: %s", err)
+	}
+	return nil
+}
+
+// statusName returns name of check status as used in output of Nagios plugins
+func statusName(status int) string {
+	switch status {
+	case ExitCodeSuccess:
+		return "OK"
+	case ExitCodeWarning:
+		return "WARNING"
+	case ExitCodeFailure:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// nativeOutput formats output of native check with given status, message and performance data
+func nativeOutput(prefix string, status int, message string, perfdata ...string) string {
+	output := fmt.Sprintf("%s %s - %s", prefix, statusName(status), message)
+	if len(perfdata) > 0 {
+		output = fmt.Sprintf("%s|%s", output, strings.Join(perfdata, " "))
+	}
+	return output + "\n"
+}
+
+// thresholdStatus returns check status of value compared to warning and critical thresholds. With above set
+// the thresholds are upper bounds, otherwise they are lower bounds. Zero threshold is disabled.
+func thresholdStatus(value, warning, critical float64, above bool) int {
+	exceeds := func(threshold float64) bool {
+		if threshold == 0 {
+			return false
+		}
+		if above {
+			return value >= threshold
+		}
+		return value <= threshold
+	}
+	switch {
+	case exceeds(critical):
+		return ExitCodeFailure
+	case exceeds(warning):
+		return ExitCodeWarning
+	}
+	return ExitCodeSuccess
+}
+
+// formatThreshold formats threshold for performance data, disabled threshold is left empty
+func formatThreshold(threshold float64) string {
+	if threshold == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(threshold, 'f', -1, 64)
+}
+
+// runNative executes native check of given name and type. Timeout of the check applies to the whole execution.
+func (self *Executor) runNative(name string, check Check, factory NativeCheckFactory) execution {
+	outcome := execution{
+		stdout: NewLimitedBuffer(self.MaxOutputSize),
+		stderr: NewLimitedBuffer(self.MaxOutputSize),
+		start:  time.Now(),
+	}
+	native, err := factory(check.Params)
+	if err != nil {
+		outcome.status, outcome.reason = ExitCodeUnknown, fmt.Sprintf("failed to prepare %s check: %s", check.Type, err)
+		return outcome
+	}
+	if named, ok := native.(namedCheck); ok {
+		named.setName(name)
+	}
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultNativeTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var output string
+	outcome.status, output = native.Run(ctx)
+	outcome.duration = time.Since(outcome.start)
+	outcome.stdout.Write([]byte(output))
+	if ctx.Err() == context.DeadlineExceeded {
+		outcome.reason = fmt.Sprintf("execution timed out after %ds", timeout)
+	}
+	return outcome
+}
@@ -0,0 +1,240 @@
+package sensu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+//CheckTypeContainer is native check type verifying health of containers
+const CheckTypeContainer = "container"
+
+//DefaultContainerSocket is API socket of the container runtime. Both podman and docker provide
+//compatible API, so socket of either can be used.
+var DefaultContainerSocket = "/run/podman/podman.sock"
+
+func init() {
+	RegisterNativeCheck(CheckTypeContainer, newContainerCheck)
+}
+
+// containerSummary is container as listed by container runtime API
+type containerSummary struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// containerHealth holds result of container healthchecks
+type containerHealth struct {
+	Status        string `json:"Status"`
+	FailingStreak int    `json:"FailingStreak"`
+	Log           []struct {
+		ExitCode int    `json:"ExitCode"`
+		Output   string `json:"Output"`
+	} `json:"Log"`
+}
+
+// containerInspect is container detail as returned by container runtime API. Older podman versions
+// report health as Healthcheck.
+type containerInspect struct {
+	Name         string `json:"Name"`
+	RestartCount int    `json:"RestartCount"`
+	State        struct {
+		Status      string           `json:"Status"`
+		ExitCode    int              `json:"ExitCode"`
+		Health      *containerHealth `json:"Health"`
+		Healthcheck *containerHealth `json:"Healthcheck"`
+	} `json:"State"`
+}
+
+// containerCheck verifies state and healthcheck status of containers matching given names or labels
+type containerCheck struct {
+	Socket      string            `json:"socket"`
+	Names       []string          `json:"names"`
+	Labels      map[string]string `json:"labels"`
+	Required    bool              `json:"required"`
+	MaxRestarts int               `json:"max_restarts"`
+	client      *http.Client
+}
+
+func newContainerCheck(params json.RawMessage) (NativeCheck, error) {
+	check := containerCheck{Socket: DefaultContainerSocket}
+	if err := decodeParams(params, &check); err != nil {
+		return nil, err
+	}
+	if len(check.Names) == 0 && len(check.Labels) == 0 {
+		return nil, fmt.Errorf("missing container name patterns or labels")
+	}
+	for _, pattern := range check.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid container name pattern %s: %s", pattern, err)
+		}
+	}
+	if check.MaxRestarts < 0 {
+		return nil, fmt.Errorf("invalid maximal number of restarts: %d", check.MaxRestarts)
+	}
+	// all API requests of the check share connection to the socket
+	check.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", check.Socket)
+			},
+		},
+	}
+	return &check, nil
+}
+
+// get requests given API endpoint of the container runtime and decodes the response to target
+func (check *containerCheck) get(ctx context.Context, endpoint string, target interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, "http://localhost"+endpoint, nil)
+	if err != nil {
+		return err
+	}
+	response, err := check.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, target)
+}
+
+// matches returns true if the container has any of the names of the check. Containers are matched
+// by labels by the runtime already.
+func (check *containerCheck) matches(container containerSummary) bool {
+	if len(check.Names) == 0 {
+		return true
+	}
+	for _, name := range container.Names {
+		name = strings.TrimPrefix(name, "/")
+		for _, pattern := range check.Names {
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containers returns containers matching names and labels of the check
+func (check *containerCheck) containers(ctx context.Context) ([]containerSummary, error) {
+	endpoint := "/containers/json?all=true"
+	if len(check.Labels) > 0 {
+		labels := []string{}
+		for key, value := range check.Labels {
+			labels = append(labels, fmt.Sprintf("%s=%s", key, value))
+		}
+		sort.Strings(labels)
+		filters, err := json.Marshal(map[string][]string{"label": labels})
+		if err != nil {
+			return nil, err
+		}
+		endpoint = fmt.Sprintf("%s&filters=%s", endpoint, url.QueryEscape(string(filters)))
+	}
+	var listed []containerSummary
+	if err := check.get(ctx, endpoint, &listed); err != nil {
+		return nil, fmt.Errorf("failed to list containers: %s", err)
+	}
+	matched := []containerSummary{}
+	for _, container := range listed {
+		if check.matches(container) {
+			matched = append(matched, container)
+		}
+	}
+	return matched, nil
+}
+
+// evaluate returns status of the container, description of the problem and last healthcheck output
+func (check *containerCheck) evaluate(container containerInspect) (int, string, string) {
+	health := container.State.Health
+	if health == nil {
+		health = container.State.Healthcheck
+	}
+	restarts := fmt.Sprintf("restarts: %d", container.RestartCount)
+	switch container.State.Status {
+	case "running":
+		if health != nil && health.Status == "unhealthy" {
+			output := ""
+			if len(health.Log) > 0 {
+				output = strings.TrimSpace(health.Log[len(health.Log)-1].Output)
+			}
+			return ExitCodeFailure, fmt.Sprintf("unhealthy, failing streak: %d, %s", health.FailingStreak, restarts), output
+		}
+	case "exited", "stopped", "dead":
+		if container.State.ExitCode != 0 || container.State.Status == "dead" {
+			return ExitCodeFailure, fmt.Sprintf("%s with exit code %d, %s", container.State.Status, container.State.ExitCode, restarts), ""
+		}
+	case "created", "configured":
+	default:
+		return ExitCodeWarning, fmt.Sprintf("%s, %s", container.State.Status, restarts), ""
+	}
+	if check.MaxRestarts > 0 && container.RestartCount > check.MaxRestarts {
+		return ExitCodeWarning, fmt.Sprintf("%s, %s", container.State.Status, restarts), ""
+	}
+	return ExitCodeSuccess, "", ""
+}
+
+func (check *containerCheck) Run(ctx context.Context) (int, string) {
+	defer check.client.CloseIdleConnections()
+	containers, err := check.containers(ctx)
+	if err != nil {
+		return ExitCodeUnknown, nativeOutput("CONTAINERS", ExitCodeUnknown, err.Error())
+	}
+	if len(containers) == 0 {
+		status := ExitCodeSuccess
+		if check.Required {
+			status = ExitCodeFailure
+		}
+		return status, nativeOutput("CONTAINERS", status, "no container matches")
+	}
+
+	status := ExitCodeSuccess
+	problems := []string{}
+	details := []string{}
+	for _, container := range containers {
+		var inspect containerInspect
+		if err := check.get(ctx, fmt.Sprintf("/containers/%s/json", url.PathEscape(container.ID)), &inspect); err != nil {
+			return ExitCodeUnknown, nativeOutput("CONTAINERS", ExitCodeUnknown, fmt.Sprintf("failed to inspect container %s: %s", container.ID, err))
+		}
+		name := strings.TrimPrefix(inspect.Name, "/")
+		current, problem, output := check.evaluate(inspect)
+		if current == ExitCodeSuccess {
+			continue
+		}
+		if current > status {
+			status = current
+		}
+		problems = append(problems, fmt.Sprintf("%s (%s)", name, problem))
+		for _, line := range strings.Split(output, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				// '|' would start performance data in the check output
+				details = append(details, fmt.Sprintf("%s: %s", name, strings.Replace(line, "|", "/", -1)))
+			}
+		}
+	}
+
+	perfdata := []string{fmt.Sprintf("containers=%d;;;0", len(containers)), fmt.Sprintf("problems=%d;;;0", len(problems))}
+	if len(problems) == 0 {
+		return status, nativeOutput("CONTAINERS", status, fmt.Sprintf("%d container(s) ok", len(containers)), perfdata...)
+	}
+	output := nativeOutput("CONTAINERS", status,
+		fmt.Sprintf("%d of %d container(s) with problems: %s", len(problems), len(containers), strings.Join(problems, ", ")), perfdata...)
+	if len(details) > 0 {
+		output += strings.Join(details, "\n") + "\n"
+	}
+	return status, output
+}
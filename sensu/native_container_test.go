@@ -0,0 +1,172 @@
+package sensu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeContainer is container served by fake container runtime API
+type fakeContainer struct {
+	summary containerSummary
+	inspect string
+}
+
+// fakeContainerAPI starts container runtime API serving given containers on unix socket. Returns path
+// to the socket and counter of accepted connections.
+func fakeContainerAPI(t *testing.T, containers []fakeContainer) (string, *int32) {
+	socket := path.Join(t.TempDir(), "api.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %s", socket, err)
+	}
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/containers/json" {
+			listed := []containerSummary{}
+			for _, container := range containers {
+				matches := true
+				for key, value := range container.summary.Labels {
+					filter := fmt.Sprintf("%s=%s", key, value)
+					matches = matches && (r.URL.Query().Get("filters") == "" || strings.Contains(r.URL.Query().Get("filters"), filter))
+				}
+				if matches {
+					listed = append(listed, container.summary)
+				}
+			}
+			json.NewEncoder(w).Encode(listed)
+			return
+		}
+		for _, container := range containers {
+			if r.URL.Path == fmt.Sprintf("/containers/%s/json", container.summary.ID) {
+				w.Write([]byte(container.inspect))
+				return
+			}
+		}
+		http.Error(w, "no such container", http.StatusNotFound)
+	}))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return socket, &connections
+}
+
+func TestContainerCheck(t *testing.T) {
+	containers := []fakeContainer{
+		{
+			summary: containerSummary{ID: "a1", Names: []string{"/nova_api"}, Labels: map[string]string{"service": "nova"}},
+			inspect: `{"Name": "/nova_api", "RestartCount": 0, "State": {"Status": "running", "Health": {"Status": "healthy"}}}`,
+		},
+		{
+			summary: containerSummary{ID: "b2", Names: []string{"/nova_compute"}, Labels: map[string]string{"service": "nova"}},
+			inspect: `{"Name": "/nova_compute", "RestartCount": 4, "State": {"Status": "running", "Healthcheck": {"Status": "unhealthy", "FailingStreak": 3, "Log": [{"ExitCode": 1, "Output": "connection | refused"}]}}}`,
+		},
+		{
+			summary: containerSummary{ID: "c3", Names: []string{"/glance_api"}, Labels: map[string]string{"service": "glance"}},
+			inspect: `{"Name": "/glance_api", "RestartCount": 0, "State": {"Status": "exited", "ExitCode": 137}}`,
+		},
+		{
+			summary: containerSummary{ID: "d4", Names: []string{"/keystone"}, Labels: map[string]string{"service": "keystone"}},
+			inspect: `{"Name": "/keystone", "RestartCount": 6, "State": {"Status": "running"}}`,
+		},
+	}
+	socket, connections := fakeContainerAPI(t, containers)
+
+	tests := []struct {
+		name   string
+		params string
+		status int
+		output []string
+	}{
+		{
+			name:   "healthy",
+			params: `{"names": ["nova_api"]}`,
+			status: ExitCodeSuccess,
+			output: []string{"CONTAINERS OK - 1 container(s) ok", "containers=1;;;0"},
+		},
+		{
+			name:   "unhealthy",
+			params: `{"names": ["nova_*"]}`,
+			status: ExitCodeFailure,
+			output: []string{"1 of 2 container(s) with problems: nova_compute (unhealthy, failing streak: 3, restarts: 4)", "nova_compute: connection / refused"},
+		},
+		{
+			name:   "exited",
+			params: `{"labels": {"service": "glance"}}`,
+			status: ExitCodeFailure,
+			output: []string{"glance_api (exited with exit code 137, restarts: 0)"},
+		},
+		{
+			name:   "restarts",
+			params: `{"names": ["keystone"], "max_restarts": 5}`,
+			status: ExitCodeWarning,
+			output: []string{"keystone (running, restarts: 6)"},
+		},
+		{
+			name:   "no match",
+			params: `{"names": ["cinder_*"]}`,
+			status: ExitCodeSuccess,
+			output: []string{"no container matches"},
+		},
+		{
+			name:   "required",
+			params: `{"names": ["cinder_*"], "required": true}`,
+			status: ExitCodeFailure,
+			output: []string{"no container matches"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := json.RawMessage(fmt.Sprintf(`{"socket": %q, %s`, socket, test.params[1:]))
+			check, err := newContainerCheck(params)
+			if err != nil {
+				t.Fatalf("failed to create check: %s", err)
+			}
+			before := atomic.LoadInt32(connections)
+			status, output := check.Run(context.Background())
+			if status != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, status, output)
+			}
+			for _, expected := range test.output {
+				if !strings.Contains(output, expected) {
+					t.Errorf("expected %q in output: %s", expected, output)
+				}
+			}
+			if count := atomic.LoadInt32(connections) - before; count != 1 {
+				t.Errorf("expected API requests of single run to share 1 connection, got %d", count)
+			}
+		})
+	}
+}
+
+func TestContainerCheckUnavailable(t *testing.T) {
+	check, err := newContainerCheck(json.RawMessage(fmt.Sprintf(`{"socket": %q, "names": ["*"]}`, path.Join(t.TempDir(), "missing.sock"))))
+	if err != nil {
+		t.Fatalf("failed to create check: %s", err)
+	}
+	status, output := check.Run(context.Background())
+	if status != ExitCodeUnknown || !strings.Contains(output, "failed to list containers") {
+		t.Errorf("expected unknown status for unavailable API, got %d: %s", status, output)
+	}
+}
+
+func TestContainerCheckParams(t *testing.T) {
+	for _, params := range []string{`{}`, `{"names": ["["]}`, `{"names": ["*"], "max_restarts": -1}`, `{"names": ["*"], "unknown": 1}`} {
+		if _, err := newContainerCheck(json.RawMessage(params)); err == nil {
+			t.Errorf("expected invalid params %s to be rejected", params)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package sensu
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+//CheckTypeLogFile is native check type searching log files for patterns
+const CheckTypeLogFile = "logfile"
+
+//StateDir is the directory where native checks persist their state between agent restarts.
+//State is kept only in memory when it is empty. It is set on startup.
+var StateDir = ""
+
+// logStateDir is subdirectory of StateDir holding offsets of log files
+const logStateDir = "logfile"
+
+// DefaultLogMaxLines is maximal number of matching lines reported in check output by default
+const DefaultLogMaxLines = 10
+
+func init() {
+	RegisterNativeCheck(CheckTypeLogFile, newLogFileCheck)
+}
+
+// logOffset is position in log file reached by previous run. Inode, size and fingerprint of the file
+// beginning detect rotation and truncation of the file.
+type logOffset struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+	Head   string `json:"head"`
+}
+
+// logHeadSize is maximal size of the file beginning used as fingerprint
+const logHeadSize = 256
+
+// fingerprint returns hash of the file beginning up to given size, so that rotated file can be
+// recognized even when the new file got inode of the old one
+func fingerprint(file *os.File, size int64) string {
+	if size > logHeadSize {
+		size = logHeadSize
+	}
+	head := make([]byte, size)
+	count, _ := file.ReadAt(head, 0)
+	return fmt.Sprintf("%x", sha1.Sum(head[:count]))
+}
+
+// logState holds offsets of log files of single check. Lock of the state serializes runs of the check.
+type logState struct {
+	sync.Mutex
+	offsets map[string]logOffset
+}
+
+// logStates caches states of all log file checks, keyed by check state key
+var (
+	logStates     = make(map[string]*logState)
+	logStatesLock sync.Mutex
+)
+
+// logPattern is pattern searched in log files with thresholds of matching line counts per run
+type logPattern struct {
+	Name     string `json:"name"`
+	Pattern  string `json:"pattern"`
+	Exclude  string `json:"exclude"`
+	Warning  int    `json:"warning"`
+	Critical int    `json:"critical"`
+	include  *regexp.Regexp
+	exclude  *regexp.Regexp
+}
+
+// logFileCheck searches lines appended to log files since previous run for patterns
+type logFileCheck struct {
+	Files         []string      `json:"files"`
+	Patterns      []*logPattern `json:"patterns"`
+	MaxLines      int           `json:"max_lines"`
+	ReadFromStart bool          `json:"read_from_start"`
+	MissingOK     bool          `json:"missing_ok"`
+	params        json.RawMessage
+	key           string
+}
+
+func newLogFileCheck(params json.RawMessage) (NativeCheck, error) {
+	check := logFileCheck{MaxLines: DefaultLogMaxLines}
+	if err := decodeParams(params, &check); err != nil {
+		return nil, err
+	}
+	if len(check.Files) == 0 {
+		return nil, fmt.Errorf("missing log files")
+	}
+	if len(check.Patterns) == 0 {
+		return nil, fmt.Errorf("missing patterns")
+	}
+	for idx, pattern := range check.Patterns {
+		var err error
+		if pattern.include, err = regexp.Compile(pattern.Pattern); err != nil || pattern.Pattern == "" {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern.Pattern, err)
+		}
+		if pattern.Exclude != "" {
+			if pattern.exclude, err = regexp.Compile(pattern.Exclude); err != nil {
+				return nil, fmt.Errorf("invalid exclude pattern %q: %s", pattern.Exclude, err)
+			}
+		}
+		if pattern.Warning == 0 && pattern.Critical == 0 {
+			pattern.Warning = 1
+		}
+		if pattern.Warning < 0 || pattern.Critical < 0 {
+			return nil, fmt.Errorf("invalid thresholds of pattern %q", pattern.Pattern)
+		}
+		if pattern.Name == "" {
+			pattern.Name = fmt.Sprintf("pattern%d", idx+1)
+		}
+	}
+	if check.MaxLines < 0 {
+		return nil, fmt.Errorf("invalid maximal number of lines: %d", check.MaxLines)
+	}
+	check.params = params
+	check.setName("")
+	return &check, nil
+}
+
+// setName sets key of the check state. Offsets are kept per check name and parameters, so that checks
+// searching the same files do not interfere and changed parameters start with fresh offsets.
+func (check *logFileCheck) setName(name string) {
+	check.key = fmt.Sprintf("%x", sha1.Sum(append([]byte(name+"\x00"), check.params...)))
+}
+
+// statePath returns path to the file with persisted offsets of the check
+func (check *logFileCheck) statePath() string {
+	return path.Join(StateDir, logStateDir, check.key+".json")
+}
+
+// state returns state of the check shared by all its runs
+func (check *logFileCheck) state() *logState {
+	logStatesLock.Lock()
+	defer logStatesLock.Unlock()
+	state, ok := logStates[check.key]
+	if !ok {
+		state = &logState{}
+		logStates[check.key] = state
+	}
+	return state
+}
+
+// loadOffsets returns offsets persisted in the state directory. Returns false when the check did not
+// run before.
+func (check *logFileCheck) loadOffsets() (map[string]logOffset, bool) {
+	offsets := make(map[string]logOffset)
+	if StateDir == "" {
+		return offsets, false
+	}
+	data, err := ioutil.ReadFile(check.statePath())
+	if err != nil || json.Unmarshal(data, &offsets) != nil {
+		return make(map[string]logOffset), false
+	}
+	return offsets, true
+}
+
+// saveOffsets persists offsets of the check to the state directory
+func (check *logFileCheck) saveOffsets(offsets map[string]logOffset) error {
+	if StateDir == "" {
+		return nil
+	}
+	data, err := json.Marshal(offsets)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Join(StateDir, logStateDir), 0700); err != nil {
+		return err
+	}
+	return writeStateFile(check.statePath(), data)
+}
+
+// files returns sorted paths of log files matching configured patterns
+func (check *logFileCheck) files() []string {
+	files := []string{}
+	for _, pattern := range check.Files {
+		matches, _ := filepath.Glob(pattern)
+		if len(matches) == 0 {
+			// missing file is reported when reading it
+			matches = []string{pattern}
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// scan reads lines appended to the file since given offset and counts matches of patterns. Returns
+// new offset. Incomplete last line is left for the next run.
+func (check *logFileCheck) scan(ctx context.Context, filePath string, previous logOffset, known bool, counts []int, lines *[]string) (logOffset, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return previous, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return previous, err
+	}
+	current := logOffset{Inode: fileInode(info), Offset: previous.Offset}
+	if !known || current.Inode != previous.Inode || info.Size() < previous.Offset || fingerprint(file, previous.Offset) != previous.Head {
+		// new, recreated or truncated file is searched from its start
+		current.Offset = 0
+	}
+	if _, err := file.Seek(current.Offset, io.SeekStart); err != nil {
+		return previous, err
+	}
+
+	reader := bufio.NewReader(file)
+	for ctx.Err() == nil {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// incomplete line is read again next time
+			break
+		}
+		current.Offset += int64(len(line))
+		line = strings.TrimRight(line, "\r\n")
+		for idx, pattern := range check.Patterns {
+			if !pattern.include.MatchString(line) || (pattern.exclude != nil && pattern.exclude.MatchString(line)) {
+				continue
+			}
+			counts[idx]++
+			if len(*lines) < check.MaxLines {
+				// '|' would start performance data in the check output
+				*lines = append(*lines, fmt.Sprintf("%s: %s", filePath, strings.Replace(line, "|", "/", -1)))
+			}
+			break
+		}
+	}
+	current.Head = fingerprint(file, current.Offset)
+	return current, nil
+}
+
+// fileInode returns inode of file with given info, zero if not available
+func fileInode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
+
+// skipToEnd sets offsets of given files to their current end
+func skipToEnd(files []string) map[string]logOffset {
+	offsets := make(map[string]logOffset)
+	for _, filePath := range files {
+		file, err := os.Open(filePath)
+		if err != nil {
+			continue
+		}
+		if info, err := file.Stat(); err == nil {
+			offsets[filePath] = logOffset{Inode: fileInode(info), Offset: info.Size(), Head: fingerprint(file, info.Size())}
+		}
+		file.Close()
+	}
+	return offsets
+}
+
+func (check *logFileCheck) Run(ctx context.Context) (int, string) {
+	state := check.state()
+	state.Lock()
+	defer state.Unlock()
+	files := check.files()
+	if state.offsets == nil {
+		var initialized bool
+		state.offsets, initialized = check.loadOffsets()
+		if !initialized && !check.ReadFromStart {
+			// only lines appended after the very first run of the check are searched
+			state.offsets = skipToEnd(files)
+		}
+	}
+	// file renamed by log rotation keeps its inode, so it is searched from its previous offset
+	byInode := make(map[uint64]logOffset)
+	for _, offset := range state.offsets {
+		if offset.Inode != 0 {
+			byInode[offset.Inode] = offset
+		}
+	}
+
+	status := ExitCodeSuccess
+	problems := []string{}
+	counts := make([]int, len(check.Patterns))
+	lines := []string{}
+	seen := make(map[string]logOffset)
+	for _, filePath := range files {
+		previous, known := state.offsets[filePath]
+		if !known {
+			if info, err := os.Stat(filePath); err == nil {
+				previous, known = byInode[fileInode(info)]
+			}
+		}
+		current, err := check.scan(ctx, filePath, previous, known, counts, &lines)
+		if err != nil {
+			if !os.IsNotExist(err) || !check.MissingOK {
+				status = ExitCodeUnknown
+				problems = append(problems, err.Error())
+			}
+			continue
+		}
+		seen[filePath] = current
+	}
+	// files which disappeared are searched from their start once they appear again
+	state.offsets = seen
+	if err := check.saveOffsets(seen); err != nil {
+		status = ExitCodeUnknown
+		problems = append(problems, fmt.Sprintf("failed to save offsets: %s", err))
+	}
+
+	matches := []string{}
+	perfdata := []string{}
+	for idx, pattern := range check.Patterns {
+		current := ExitCodeSuccess
+		if pattern.Critical > 0 && counts[idx] >= pattern.Critical {
+			current = ExitCodeFailure
+		} else if pattern.Warning > 0 && counts[idx] >= pattern.Warning {
+			current = ExitCodeWarning
+		}
+		// critical matches outweigh failures to read some of the files
+		if current == ExitCodeFailure || (current == ExitCodeWarning && status == ExitCodeSuccess) {
+			status = current
+		}
+		if counts[idx] > 0 {
+			matches = append(matches, fmt.Sprintf("%d line(s) matching %s", counts[idx], pattern.Name))
+		}
+		perfdata = append(perfdata, fmt.Sprintf("'%s'=%d;%s;%s;0", pattern.Name, counts[idx],
+			formatThreshold(float64(pattern.Warning)), formatThreshold(float64(pattern.Critical))))
+	}
+
+	message := "no matching lines"
+	if len(matches) > 0 {
+		message = strings.Join(matches, ", ")
+	}
+	if len(problems) > 0 {
+		message = fmt.Sprintf("%s; %s", strings.Join(problems, ", "), message)
+	}
+	output := nativeOutput("LOG", status, message, perfdata...)
+	if len(lines) > 0 {
+		output += strings.Join(lines, "\n") + "\n"
+	}
+	return status, output
+}
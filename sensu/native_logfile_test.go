@@ -0,0 +1,146 @@
+package sensu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+)
+
+func TestLogFileCheckNames(t *testing.T) {
+	defer func(dir string) { StateDir = dir }(StateDir)
+	StateDir = t.TempDir()
+
+	logPath := path.Join(t.TempDir(), "messages")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		t.Fatalf("failed to create log file: %s", err)
+	}
+	defer logFile.Close()
+	fmt.Fprintln(logFile, "ERROR: first")
+
+	params := json.RawMessage(fmt.Sprintf(`{"files": [%q], "patterns": [{"pattern": "ERROR"}], "read_from_start": true}`, logPath))
+	executor := newTestExecutor(t, map[string]Check{
+		"check-a": {Type: CheckTypeLogFile, Params: params},
+		"check-b": {Type: CheckTypeLogFile, Params: params},
+	})
+	run := func(name string) int {
+		result, err := executor.Execute(Request{CheckRequest: connector.CheckRequest{Name: name}})
+		if err != nil {
+			t.Fatalf("failed to execute %s: %s", name, err)
+		}
+		return result.Result.Status
+	}
+
+	// checks with the same parameters see the same lines
+	for _, name := range []string{"check-a", "check-b"} {
+		if status := run(name); status != ExitCodeWarning {
+			t.Errorf("%s: expected matching line to be found, got status %d", name, status)
+		}
+	}
+	fmt.Fprintln(logFile, "ERROR: second")
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"check-a", ExitCodeWarning},
+		{"check-b", ExitCodeWarning},
+		{"check-a", ExitCodeSuccess},
+	}
+	for _, test := range tests {
+		if status := run(test.name); status != test.status {
+			t.Errorf("%s: expected status %d after new line, got %d", test.name, test.status, status)
+		}
+	}
+}
+
+func TestLogFileCheckRotation(t *testing.T) {
+	defer func(dir string) { StateDir = dir }(StateDir)
+	StateDir = t.TempDir()
+
+	dir := t.TempDir()
+	logPath := path.Join(dir, "messages")
+	appendLines := func(filePath string, lines ...string) {
+		file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatalf("failed to open %s: %s", filePath, err)
+		}
+		defer file.Close()
+		for _, line := range lines {
+			fmt.Fprintln(file, line)
+		}
+	}
+	appendLines(logPath, "ERROR: before start")
+
+	params := json.RawMessage(fmt.Sprintf(`{"files": [%q], "patterns": [{"pattern": "ERROR"}], "missing_ok": true}`, path.Join(dir, "messages*")))
+	check, err := newLogFileCheck(params)
+	if err != nil {
+		t.Fatalf("failed to create check: %s", err)
+	}
+
+	steps := []struct {
+		name     string
+		prepare  func()
+		expected []string
+	}{
+		{"first run", func() {}, nil},
+		{"appended", func() { appendLines(logPath, "ERROR: one") }, []string{"ERROR: one"}},
+		{"renamed", func() {
+			appendLines(logPath, "ERROR: two")
+			if err := os.Rename(logPath, logPath+".1"); err != nil {
+				t.Fatalf("failed to rotate log file: %s", err)
+			}
+			appendLines(logPath, "ERROR: three")
+		}, []string{"ERROR: two", "ERROR: three"}},
+		{"truncated", func() {
+			if err := os.Truncate(logPath, 0); err != nil {
+				t.Fatalf("failed to truncate log file: %s", err)
+			}
+			appendLines(logPath, "ERROR: four")
+		}, []string{"ERROR: four"}},
+		{"removed", func() {
+			if err := os.Remove(logPath); err != nil {
+				t.Fatalf("failed to remove log file: %s", err)
+			}
+		}, nil},
+		{"recreated", func() { appendLines(logPath, "ERROR: five") }, []string{"ERROR: five"}},
+		{"restarted", func() {
+			appendLines(logPath, "ERROR: six")
+			// restarted agent has to continue from persisted offsets
+			logStatesLock.Lock()
+			delete(logStates, check.(*logFileCheck).key)
+			logStatesLock.Unlock()
+		}, []string{"ERROR: six"}},
+	}
+	reported := []string{}
+	for _, step := range steps {
+		step.prepare()
+		status, output := check.Run(context.Background())
+		expectedStatus := ExitCodeSuccess
+		if len(step.expected) > 0 {
+			expectedStatus = ExitCodeWarning
+		}
+		if status != expectedStatus {
+			t.Errorf("%s: expected status %d, got %d: %s", step.name, expectedStatus, status, output)
+		}
+		for _, line := range step.expected {
+			if !strings.Contains(output, line) {
+				t.Errorf("%s: expected %q in output: %s", step.name, line, output)
+			}
+		}
+		for _, line := range reported {
+			if strings.Contains(output, line+"\n") {
+				t.Errorf("%s: line %q reported again: %s", step.name, line, output)
+			}
+		}
+		if strings.Contains(output, "before start") {
+			t.Errorf("%s: line written before the first run reported: %s", step.name, output)
+		}
+		reported = append(reported, step.expected...)
+	}
+}
@@ -0,0 +1,167 @@
+package sensu
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Native check types probing network services
+const (
+	CheckTypeTCP  = "tcp"
+	CheckTypeHTTP = "http"
+)
+
+// maxHTTPBodySize limits size of HTTP response body read for pattern matching
+const maxHTTPBodySize = 1 << 20
+
+func init() {
+	RegisterNativeCheck(CheckTypeTCP, newTCPCheck)
+	RegisterNativeCheck(CheckTypeHTTP, newHTTPCheck)
+}
+
+// tcpCheck verifies that TCP connection to given port can be established
+type tcpCheck struct {
+	Host     string  `json:"host"`
+	Port     int     `json:"port"`
+	Warning  float64 `json:"warning"`
+	Critical float64 `json:"critical"`
+}
+
+func newTCPCheck(params json.RawMessage) (NativeCheck, error) {
+	check := tcpCheck{Host: "localhost"}
+	if err := decodeParams(params, &check); err != nil {
+		return nil, err
+	}
+	if check.Port < 1 || check.Port > 65535 {
+		return nil, fmt.Errorf("invalid port: %d", check.Port)
+	}
+	return &check, nil
+}
+
+func (check *tcpCheck) Run(ctx context.Context) (int, string) {
+	address := net.JoinHostPort(check.Host, strconv.Itoa(check.Port))
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return ExitCodeFailure, nativeOutput("TCP", ExitCodeFailure, fmt.Sprintf("connection to %s failed: %s", address, err))
+	}
+	elapsed := time.Since(start).Seconds()
+	conn.Close()
+
+	status := thresholdStatus(elapsed, check.Warning, check.Critical, true)
+	return status, nativeOutput("TCP", status,
+		fmt.Sprintf("%.3f second response time on %s", elapsed, address),
+		fmt.Sprintf("time=%fs;%s;%s;0", elapsed, formatThreshold(check.Warning), formatThreshold(check.Critical)))
+}
+
+// httpCheck verifies HTTP(S) response status and optionally content of the response body
+type httpCheck struct {
+	URL      string            `json:"url"`
+	Method   string            `json:"method"`
+	Headers  map[string]string `json:"headers"`
+	Status   []int             `json:"status"`
+	Body     string            `json:"body"`
+	Insecure bool              `json:"insecure"`
+	Warning  float64           `json:"warning"`
+	Critical float64           `json:"critical"`
+	pattern  *regexp.Regexp
+}
+
+func newHTTPCheck(params json.RawMessage) (NativeCheck, error) {
+	check := httpCheck{Method: http.MethodGet}
+	if err := decodeParams(params, &check); err != nil {
+		return nil, err
+	}
+	parsed, err := url.Parse(check.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %s", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid url scheme: %s", check.URL)
+	}
+	if check.Body != "" {
+		if check.pattern, err = regexp.Compile(check.Body); err != nil {
+			return nil, fmt.Errorf("invalid body pattern: %s", err)
+		}
+	}
+	return &check, nil
+}
+
+// expectedStatus returns true if the response status is the expected one. Any status lower than 400
+// is expected when no status is given.
+func (check *httpCheck) expectedStatus(code int) bool {
+	if len(check.Status) == 0 {
+		return code < http.StatusBadRequest
+	}
+	for _, expected := range check.Status {
+		if code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+func (check *httpCheck) Run(ctx context.Context) (int, string) {
+	request, err := http.NewRequest(check.Method, check.URL, nil)
+	if err != nil {
+		return ExitCodeUnknown, nativeOutput("HTTP", ExitCodeUnknown, err.Error())
+	}
+	request = request.WithContext(ctx)
+	for key, value := range check.Headers {
+		request.Header.Set(key, value)
+		if strings.EqualFold(key, "Host") {
+			request.Host = value
+		}
+	}
+	// single request is sent per execution, so connections are not kept for reuse
+	client := http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: check.Insecure},
+			DisableKeepAlives: true,
+		},
+		// redirects are reported as they are, same as check_http does by default
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	start := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		return ExitCodeFailure, nativeOutput("HTTP", ExitCodeFailure, err.Error())
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxHTTPBodySize))
+	elapsed := time.Since(start).Seconds()
+	if err != nil {
+		return ExitCodeFailure, nativeOutput("HTTP", ExitCodeFailure, fmt.Sprintf("failed to read response: %s", err))
+	}
+
+	perfdata := []string{
+		fmt.Sprintf("time=%fs;%s;%s;0", elapsed, formatThreshold(check.Warning), formatThreshold(check.Critical)),
+		fmt.Sprintf("size=%dB;;;0", len(body)),
+	}
+	message := fmt.Sprintf("%s %s - %d bytes in %.3f second response time", response.Proto, response.Status, len(body), elapsed)
+	if !check.expectedStatus(response.StatusCode) {
+		return ExitCodeFailure, nativeOutput("HTTP", ExitCodeFailure, fmt.Sprintf("unexpected status: %s", message), perfdata...)
+	}
+	if check.pattern != nil && !check.pattern.Match(body) {
+		return ExitCodeFailure, nativeOutput("HTTP", ExitCodeFailure, fmt.Sprintf("pattern not found: %s", message), perfdata...)
+	}
+	status := thresholdStatus(elapsed, check.Warning, check.Critical, true)
+	return status, nativeOutput("HTTP", status, message, perfdata...)
+}
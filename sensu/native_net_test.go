@@ -0,0 +1,150 @@
+package sensu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// closedPort returns address of local TCP port nothing listens on
+func closedPort(t *testing.T) (string, int) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	listener.Close()
+	return addr.IP.String(), addr.Port
+}
+
+// runNativeCheck creates native check of given type and runs it
+func runNativeCheck(t *testing.T, checkType string, params string) (int, string) {
+	check, err := nativeChecks[checkType](json.RawMessage(params))
+	if err != nil {
+		t.Fatalf("failed to create %s check with %s: %s", checkType, params, err)
+	}
+	return check.Run(context.Background())
+}
+
+// expectPerfdata verifies that given output contains perfdata metrics of given names with given thresholds
+func expectPerfdata(t *testing.T, output string, thresholds map[string][2]string) {
+	_, metrics := ParsePerfdata(output)
+	found := make(map[string]Metric)
+	for _, metric := range metrics {
+		found[metric.Name] = metric
+	}
+	for name, expected := range thresholds {
+		metric, ok := found[name]
+		if !ok {
+			t.Errorf("expected %s in perfdata of output: %s", name, output)
+			continue
+		}
+		if metric.Warning != expected[0] || metric.Critical != expected[1] {
+			t.Errorf("expected %s thresholds %v, got %q and %q", name, expected, metric.Warning, metric.Critical)
+		}
+	}
+}
+
+func TestNativeCheckParams(t *testing.T) {
+	tests := map[string][]string{
+		CheckTypeTCP:     {`{}`, `{"port": 0}`, `{"port": 65536}`, `{"port": "80"}`, `{"port": 80, "unknown": 1}`},
+		CheckTypeHTTP:    {`{}`, `{"url": "ftp://localhost/"}`, `{"url": "http://localhost/", "body": "["}`, `{"url": ":"}`},
+		CheckTypeDisk:    {`{"warning": 101}`, `{"critical": -1}`, `{"path": 1}`},
+		CheckTypeFileAge: {`{}`, `{"path": "/tmp", "warning": "1"}`},
+		CheckTypeProcess: {`{}`, `{"name": "sshd", "min": -1}`, `{"name": "sshd", "min": 3, "max": 2}`},
+		CheckTypeLoad:    {`{"warning": [1, 2]}`, `{"critical": [1, 2, 3, 4]}`, `{"per_cpu": "yes"}`},
+	}
+	for checkType, invalid := range tests {
+		for _, params := range invalid {
+			if _, err := nativeChecks[checkType](json.RawMessage(params)); err == nil {
+				t.Errorf("expected %s check params %s to be rejected", checkType, params)
+			}
+		}
+	}
+}
+
+func TestTCPCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	closedHost, closed := closedPort(t)
+
+	tests := []struct {
+		name     string
+		params   string
+		status   int
+		output   string
+		perfdata map[string][2]string
+	}{
+		{"open", fmt.Sprintf(`{"host": "127.0.0.1", "port": %d, "warning": 5, "critical": 10}`, port), ExitCodeSuccess, "TCP OK", map[string][2]string{"time": {"5", "10"}}},
+		{"slow", fmt.Sprintf(`{"host": "127.0.0.1", "port": %d, "warning": 0.000000001}`, port), ExitCodeWarning, "TCP WARNING", map[string][2]string{"time": {"0.000000001", ""}}},
+		{"closed", fmt.Sprintf(`{"host": %q, "port": %d}`, closedHost, closed), ExitCodeFailure, "connection to", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, output := runNativeCheck(t, CheckTypeTCP, test.params)
+			if status != test.status || !strings.Contains(output, test.output) {
+				t.Errorf("expected status %d with %q, got %d: %s", test.status, test.output, status, output)
+			}
+			expectPerfdata(t, output, test.perfdata)
+		})
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "hello from %s", r.Host)
+		case "/redirect":
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "/post":
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	closedHost, closed := closedPort(t)
+
+	tests := []struct {
+		name     string
+		params   string
+		status   int
+		output   string
+		perfdata map[string][2]string
+	}{
+		{"ok", `{"url": "%s/", "warning": 5, "critical": 10}`, ExitCodeSuccess, "HTTP OK - HTTP/1.1 200 OK", map[string][2]string{"time": {"5", "10"}, "size": {"", ""}}},
+		{"body", `{"url": "%s/", "body": "hello from [a-z.]+$", "headers": {"Host": "example.com"}}`, ExitCodeSuccess, "HTTP OK", nil},
+		{"body mismatch", `{"url": "%s/", "body": "goodbye"}`, ExitCodeFailure, "pattern not found", map[string][2]string{"size": {"", ""}}},
+		{"not found", `{"url": "%s/missing"}`, ExitCodeFailure, "unexpected status: HTTP/1.1 404 Not Found", nil},
+		{"expected status", `{"url": "%s/missing", "status": [404]}`, ExitCodeSuccess, "404 Not Found", nil},
+		{"redirect", `{"url": "%s/redirect"}`, ExitCodeSuccess, "302 Found", nil},
+		{"method", `{"url": "%s/post", "method": "POST"}`, ExitCodeSuccess, "200 OK", nil},
+		{"slow", `{"url": "%s/", "critical": 0.000000001}`, ExitCodeFailure, "HTTP CRITICAL", map[string][2]string{"time": {"", "0.000000001"}}},
+		{"closed", fmt.Sprintf(`{"url": "http://%s:%d/"}`, closedHost, closed), ExitCodeFailure, "connection refused", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := test.params
+			if strings.Contains(params, "%s") {
+				params = fmt.Sprintf(params, server.URL)
+			}
+			status, output := runNativeCheck(t, CheckTypeHTTP, params)
+			if status != test.status || !strings.Contains(output, test.output) {
+				t.Errorf("expected status %d with %q, got %d: %s", test.status, test.output, status, output)
+			}
+			expectPerfdata(t, output, test.perfdata)
+		})
+	}
+}
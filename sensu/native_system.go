@@ -0,0 +1,264 @@
+package sensu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+//Native check types inspecting local system
+const (
+	CheckTypeDisk    = "disk"
+	CheckTypeFileAge = "file_age"
+	CheckTypeProcess = "process"
+	CheckTypeLoad    = "load"
+)
+
+//Paths to proc files used by native checks
+var (
+	ProcPath    = "/proc"
+	LoadAvgPath = "/proc/loadavg"
+)
+
+func init() {
+	RegisterNativeCheck(CheckTypeDisk, newDiskCheck)
+	RegisterNativeCheck(CheckTypeFileAge, newFileAgeCheck)
+	RegisterNativeCheck(CheckTypeProcess, newProcessCheck)
+	RegisterNativeCheck(CheckTypeLoad, newLoadCheck)
+}
+
+// diskCheck verifies free space of filesystem in percent
+type diskCheck struct {
+	Path     string  `json:"path"`
+	Warning  float64 `json:"warning"`
+	Critical float64 `json:"critical"`
+}
+
+func newDiskCheck(params json.RawMessage) (NativeCheck, error) {
+	check := diskCheck{Path: "/", Warning: 20, Critical: 10}
+	if err := decodeParams(params, &check); err != nil {
+		return nil, err
+	}
+	if check.Warning < 0 || check.Warning > 100 || check.Critical < 0 || check.Critical > 100 {
+		return nil, fmt.Errorf("thresholds have to be percentage of free space")
+	}
+	return &check, nil
+}
+
+func (check *diskCheck) Run(ctx context.Context) (int, string) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(check.Path, &stat); err != nil {
+		return ExitCodeUnknown, nativeOutput("DISK", ExitCodeUnknown, fmt.Sprintf("failed to get filesystem statistics of %s: %s", check.Path, err))
+	}
+	blockSize := uint64(stat.Bsize)
+	total := stat.Blocks * blockSize
+	free := stat.Bavail * blockSize
+	// space reserved for root is not available, so it does not count to usable space
+	usable := total - (stat.Bfree-stat.Bavail)*blockSize
+	percent := 100.0
+	if usable > 0 {
+		percent = float64(free) * 100 / float64(usable)
+	}
+
+	const mebibyte = 1024 * 1024
+	status := thresholdStatus(percent, check.Warning, check.Critical, false)
+	warning, critical := "", ""
+	if check.Warning > 0 {
+		warning = strconv.FormatUint(uint64(float64(usable)*(100-check.Warning)/100)/mebibyte, 10)
+	}
+	if check.Critical > 0 {
+		critical = strconv.FormatUint(uint64(float64(usable)*(100-check.Critical)/100)/mebibyte, 10)
+	}
+	return status, nativeOutput("DISK", status,
+		fmt.Sprintf("free space: %s %d MB (%.0f%%)", check.Path, free/mebibyte, percent),
+		fmt.Sprintf("'%s'=%dMB;%s;%s;0;%d", check.Path, (usable-free)/mebibyte, warning, critical, usable/mebibyte))
+}
+
+// fileAgeCheck verifies age of file modification time in seconds
+type fileAgeCheck struct {
+	Path     string  `json:"path"`
+	Warning  float64 `json:"warning"`
+	Critical float64 `json:"critical"`
+}
+
+func newFileAgeCheck(params json.RawMessage) (NativeCheck, error) {
+	var check fileAgeCheck
+	if err := decodeParams(params, &check); err != nil {
+		return nil, err
+	}
+	if check.Path == "" {
+		return nil, fmt.Errorf("missing path")
+	}
+	return &check, nil
+}
+
+func (check *fileAgeCheck) Run(ctx context.Context) (int, string) {
+	info, err := os.Stat(check.Path)
+	if err != nil {
+		return ExitCodeFailure, nativeOutput("FILE_AGE", ExitCodeFailure, err.Error())
+	}
+	age := time.Since(info.ModTime()).Seconds()
+	if age < 0 {
+		age = 0
+	}
+	status := thresholdStatus(age, check.Warning, check.Critical, true)
+	return status, nativeOutput("FILE_AGE", status,
+		fmt.Sprintf("%s is %.0f seconds old and %d bytes", check.Path, age, info.Size()),
+		fmt.Sprintf("age=%.0fs;%s;%s;0", age, formatThreshold(check.Warning), formatThreshold(check.Critical)),
+		fmt.Sprintf("size=%dB;;;0", info.Size()))
+}
+
+// processCheck verifies number of running processes with given name
+type processCheck struct {
+	Name string `json:"name"`
+	Min  int    `json:"min"`
+	Max  int    `json:"max"`
+}
+
+func newProcessCheck(params json.RawMessage) (NativeCheck, error) {
+	check := processCheck{Min: 1}
+	if err := decodeParams(params, &check); err != nil {
+		return nil, err
+	}
+	if check.Name == "" {
+		return nil, fmt.Errorf("missing process name")
+	}
+	if check.Min < 0 || (check.Max > 0 && check.Max < check.Min) {
+		return nil, fmt.Errorf("invalid process count range: %d-%d", check.Min, check.Max)
+	}
+	return &check, nil
+}
+
+// matches returns true if process with given PID has the name of the check. Either name of the process
+// or basename of its executable has to match, because process names are truncated by the kernel.
+func (check *processCheck) matches(pid string) bool {
+	comm, err := ioutil.ReadFile(path.Join(ProcPath, pid, "comm"))
+	if err != nil {
+		return false
+	}
+	if strings.TrimSpace(string(comm)) == check.Name {
+		return true
+	}
+	cmdline, err := ioutil.ReadFile(path.Join(ProcPath, pid, "cmdline"))
+	if err != nil || len(cmdline) == 0 {
+		return false
+	}
+	return filepath.Base(strings.SplitN(string(cmdline), "\x00", 2)[0]) == check.Name
+}
+
+func (check *processCheck) Run(ctx context.Context) (int, string) {
+	entries, err := ioutil.ReadDir(ProcPath)
+	if err != nil {
+		return ExitCodeUnknown, nativeOutput("PROCS", ExitCodeUnknown, fmt.Sprintf("failed to list processes: %s", err))
+	}
+	count := 0
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		if check.matches(entry.Name()) {
+			count++
+		}
+	}
+
+	status := ExitCodeSuccess
+	if count < check.Min || (check.Max > 0 && count > check.Max) {
+		status = ExitCodeFailure
+	}
+	max := ""
+	if check.Max > 0 {
+		max = strconv.Itoa(check.Max)
+	}
+	return status, nativeOutput("PROCS", status,
+		fmt.Sprintf("%d process(es) with name '%s'", count, check.Name),
+		fmt.Sprintf("procs=%d;;%d:%s;0", count, check.Min, max))
+}
+
+// loadCheck verifies 1, 5 and 15 minute load averages
+type loadCheck struct {
+	Warning  []float64 `json:"warning"`
+	Critical []float64 `json:"critical"`
+	PerCPU   bool      `json:"per_cpu"`
+}
+
+func newLoadCheck(params json.RawMessage) (NativeCheck, error) {
+	var check loadCheck
+	if err := decodeParams(params, &check); err != nil {
+		return nil, err
+	}
+	for _, thresholds := range [][]float64{check.Warning, check.Critical} {
+		if len(thresholds) != 0 && len(thresholds) != 3 {
+			return nil, fmt.Errorf("thresholds have to be given for 1, 5 and 15 minute load averages")
+		}
+	}
+	return &check, nil
+}
+
+// threshold returns threshold for load average of given index, zero if disabled
+func threshold(thresholds []float64, idx int) float64 {
+	if len(thresholds) == 0 {
+		return 0
+	}
+	return thresholds[idx]
+}
+
+func (check *loadCheck) Run(ctx context.Context) (int, string) {
+	data, err := ioutil.ReadFile(LoadAvgPath)
+	if err != nil {
+		return ExitCodeUnknown, nativeOutput("LOAD", ExitCodeUnknown, fmt.Sprintf("failed to read load average: %s", err))
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return ExitCodeUnknown, nativeOutput("LOAD", ExitCodeUnknown, fmt.Sprintf("invalid content of %s", LoadAvgPath))
+	}
+	cpus := 1.0
+	if check.PerCPU {
+		if count, err := onlineCPUs(); err == nil {
+			cpus = float64(count)
+		}
+	}
+
+	status := ExitCodeSuccess
+	loads := make([]string, 3)
+	perfdata := make([]string, 3)
+	for idx, name := range []string{"load1", "load5", "load15"} {
+		load, err := strconv.ParseFloat(fields[idx], 64)
+		if err != nil {
+			return ExitCodeUnknown, nativeOutput("LOAD", ExitCodeUnknown, fmt.Sprintf("invalid load average: %s", fields[idx]))
+		}
+		load /= cpus
+		warning, critical := threshold(check.Warning, idx), threshold(check.Critical, idx)
+		if current := thresholdStatus(load, warning, critical, true); current > status {
+			status = current
+		}
+		loads[idx] = strconv.FormatFloat(load, 'f', 2, 64)
+		perfdata[idx] = fmt.Sprintf("%s=%s;%s;%s;0", name, loads[idx], formatThreshold(warning), formatThreshold(critical))
+	}
+	return status, nativeOutput("LOAD", status, fmt.Sprintf("load average: %s", strings.Join(loads, ", ")), perfdata...)
+}
+
+// onlineCPUs returns number of online CPUs according to /proc/stat
+func onlineCPUs() (int, error) {
+	data, err := ioutil.ReadFile(path.Join(ProcPath, "stat"))
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "cpu") && len(line) > 3 && line[3] >= '0' && line[3] <= '9' {
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("no CPU found")
+	}
+	return count, nil
+}
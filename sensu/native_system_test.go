@@ -0,0 +1,169 @@
+package sensu
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFiles creates given files with given content under given directory
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		filePath := path.Join(dir, name)
+		if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+			t.Fatalf("failed to create directory for %s: %s", filePath, err)
+		}
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %s", filePath, err)
+		}
+	}
+}
+
+func TestDiskCheck(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		params   string
+		status   int
+		output   string
+		perfdata map[string][2]string
+	}{
+		{"ok", fmt.Sprintf(`{"path": %q, "warning": 0, "critical": 0}`, dir), ExitCodeSuccess, "DISK OK - free space: " + dir, map[string][2]string{dir: {"", ""}}},
+		{"warning", fmt.Sprintf(`{"path": %q, "warning": 100, "critical": 0}`, dir), ExitCodeWarning, "DISK WARNING", map[string][2]string{dir: {"0", ""}}},
+		{"critical", fmt.Sprintf(`{"path": %q, "warning": 100, "critical": 100}`, dir), ExitCodeFailure, "DISK CRITICAL", map[string][2]string{dir: {"0", "0"}}},
+		{"missing", fmt.Sprintf(`{"path": %q}`, path.Join(dir, "missing")), ExitCodeUnknown, "failed to get filesystem statistics", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, output := runNativeCheck(t, CheckTypeDisk, test.params)
+			if status != test.status || !strings.Contains(output, test.output) {
+				t.Errorf("expected status %d with %q, got %d: %s", test.status, test.output, status, output)
+			}
+			expectPerfdata(t, output, test.perfdata)
+		})
+	}
+}
+
+func TestFileAgeCheck(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		age      time.Duration
+		params   string
+		status   int
+		output   string
+		perfdata map[string][2]string
+	}{
+		{"fresh", 0, `{"path": %q, "warning": 60, "critical": 120}`, ExitCodeSuccess, "FILE_AGE OK", map[string][2]string{"age": {"60", "120"}, "size": {"", ""}}},
+		{"old", 90 * time.Second, `{"path": %q, "warning": 60, "critical": 120}`, ExitCodeWarning, "FILE_AGE WARNING", nil},
+		{"stale", time.Hour, `{"path": %q, "warning": 60, "critical": 120}`, ExitCodeFailure, "FILE_AGE CRITICAL", nil},
+		{"no thresholds", time.Hour, `{"path": %q}`, ExitCodeSuccess, "is 3600 seconds old and 5 bytes", map[string][2]string{"age": {"", ""}}},
+		{"missing", -1, `{"path": %q, "warning": 60}`, ExitCodeFailure, "no such file or directory", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filePath := path.Join(dir, strings.Replace(test.name, " ", "_", -1))
+			if test.age >= 0 {
+				writeFiles(t, dir, map[string]string{path.Base(filePath): "data\n"})
+				modified := time.Now().Add(-test.age)
+				if err := os.Chtimes(filePath, modified, modified); err != nil {
+					t.Fatalf("failed to change times of %s: %s", filePath, err)
+				}
+			}
+			status, output := runNativeCheck(t, CheckTypeFileAge, fmt.Sprintf(test.params, filePath))
+			if status != test.status || !strings.Contains(output, test.output) {
+				t.Errorf("expected status %d with %q, got %d: %s", test.status, test.output, status, output)
+			}
+			expectPerfdata(t, output, test.perfdata)
+		})
+	}
+}
+
+func TestProcessCheck(t *testing.T) {
+	defer func(procPath string) { ProcPath = procPath }(ProcPath)
+	ProcPath = t.TempDir()
+	writeFiles(t, ProcPath, map[string]string{
+		"1/comm":       "systemd\n",
+		"1/cmdline":    "/usr/lib/systemd/systemd\x00--system\x00",
+		"100/comm":     "sshd\n",
+		"100/cmdline":  "/usr/sbin/sshd\x00-D\x00",
+		"101/comm":     "sshd-session\n",
+		"101/cmdline":  "/usr/sbin/sshd\x00-R\x00",
+		"102/comm":     "bash\n",
+		"102/cmdline":  "",
+		"103/comm":     "kworker/0:1\n",
+		"self/comm":    "sshd\n",
+		"self/cmdline": "/usr/sbin/sshd\x00",
+		"stat":         "cpu  1 2 3 4\n",
+	})
+
+	tests := []struct {
+		name   string
+		params string
+		status int
+		output string
+	}{
+		{"running", `{"name": "sshd"}`, ExitCodeSuccess, "PROCS OK - 2 process(es) with name 'sshd'|procs=2;;1:;0"},
+		{"comm only", `{"name": "bash"}`, ExitCodeSuccess, "1 process(es) with name 'bash'"},
+		{"not running", `{"name": "httpd"}`, ExitCodeFailure, "PROCS CRITICAL - 0 process(es)"},
+		{"too few", `{"name": "sshd", "min": 3}`, ExitCodeFailure, "procs=2;;3:;0"},
+		{"too many", `{"name": "sshd", "max": 1}`, ExitCodeFailure, "procs=2;;1:1;0"},
+		{"in range", `{"name": "sshd", "min": 2, "max": 2}`, ExitCodeSuccess, "procs=2;;2:2;0"},
+		{"allowed absence", `{"name": "httpd", "min": 0}`, ExitCodeSuccess, "PROCS OK"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, output := runNativeCheck(t, CheckTypeProcess, test.params)
+			if status != test.status || !strings.Contains(output, test.output) {
+				t.Errorf("expected status %d with %q, got %d: %s", test.status, test.output, status, output)
+			}
+		})
+	}
+}
+
+func TestLoadCheck(t *testing.T) {
+	defer func(procPath, loadAvgPath string) {
+		ProcPath = procPath
+		LoadAvgPath = loadAvgPath
+	}(ProcPath, LoadAvgPath)
+	ProcPath = t.TempDir()
+	LoadAvgPath = path.Join(ProcPath, "loadavg")
+	writeFiles(t, ProcPath, map[string]string{
+		"loadavg": "4.00 2.00 1.00 3/512 4242\n",
+		"stat":    "cpu  10 0 10 100\ncpu0 1 0 1 25\ncpu1 1 0 1 25\ncpu2 1 0 1 25\ncpu3 1 0 1 25\nintr 12345\ncpufreq 0\n",
+	})
+
+	tests := []struct {
+		name     string
+		params   string
+		status   int
+		output   string
+		perfdata map[string][2]string
+	}{
+		{"no thresholds", `{}`, ExitCodeSuccess, "LOAD OK - load average: 4.00, 2.00, 1.00", map[string][2]string{"load1": {"", ""}, "load15": {"", ""}}},
+		{"warning", `{"warning": [3, 3, 3], "critical": [5, 5, 5]}`, ExitCodeWarning, "LOAD WARNING", map[string][2]string{"load1": {"3", "5"}, "load5": {"3", "5"}}},
+		{"critical", `{"warning": [1, 1, 1], "critical": [8, 2, 8]}`, ExitCodeFailure, "LOAD CRITICAL", nil},
+		{"per cpu", `{"warning": [1, 1, 1], "critical": [2, 2, 2], "per_cpu": true}`, ExitCodeWarning, "load average: 1.00, 0.50, 0.25", map[string][2]string{"load1": {"1", "2"}}},
+		{"per cpu ok", `{"warning": [1.5, 1.5, 1.5], "per_cpu": true}`, ExitCodeSuccess, "LOAD OK", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, output := runNativeCheck(t, CheckTypeLoad, test.params)
+			if status != test.status || !strings.Contains(output, test.output) {
+				t.Errorf("expected status %d with %q, got %d: %s", test.status, test.output, status, output)
+			}
+			expectPerfdata(t, output, test.perfdata)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		writeFiles(t, ProcPath, map[string]string{"loadavg": "4.00 abc\n"})
+		if status, output := runNativeCheck(t, CheckTypeLoad, `{}`); status != ExitCodeUnknown {
+			t.Errorf("expected unknown status for invalid load average, got %d: %s", status, output)
+		}
+	})
+}
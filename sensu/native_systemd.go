@@ -0,0 +1,189 @@
+package sensu
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+)
+
+//CheckTypeSystemd is native check type verifying state of systemd units
+const CheckTypeSystemd = "systemd"
+
+//Paths to systemd tools used by systemd check
+var (
+	SystemctlPath  = "systemctl"
+	JournalctlPath = "journalctl"
+)
+
+func init() {
+	RegisterNativeCheck(CheckTypeSystemd, newSystemdCheck)
+}
+
+// systemdUnit holds properties of systemd unit as reported by systemctl show
+type systemdUnit map[string]string
+
+// systemdCheck verifies that none of units matching given patterns is in failed state
+type systemdCheck struct {
+	Units            []string `json:"units"`
+	Failed           []string `json:"failed"`
+	Required         bool     `json:"required"`
+	JournalLines     int      `json:"journal_lines"`
+	JournalDirectory string   `json:"journal_directory"`
+}
+
+func newSystemdCheck(params json.RawMessage) (NativeCheck, error) {
+	check := systemdCheck{Failed: []string{"failed"}, JournalLines: 5}
+	if err := decodeParams(params, &check); err != nil {
+		return nil, err
+	}
+	if len(check.Units) == 0 {
+		return nil, fmt.Errorf("missing unit patterns")
+	}
+	for _, pattern := range check.Units {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid unit pattern %s: %s", pattern, err)
+		}
+	}
+	if check.JournalLines < 0 {
+		return nil, fmt.Errorf("invalid number of journal lines: %d", check.JournalLines)
+	}
+	return &check, nil
+}
+
+// units returns sorted names of loaded units matching patterns of the check
+func (check *systemdCheck) units(ctx context.Context) ([]string, error) {
+	out, err := exec.CommandContext(ctx, SystemctlPath, "list-units", "--all", "--plain", "--full", "--no-legend", "--no-pager").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list units: %s", commandError(err))
+	}
+	units := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		for _, pattern := range check.Units {
+			if matched, _ := path.Match(pattern, fields[0]); matched {
+				units = append(units, fields[0])
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read unit list: %s", err)
+	}
+	sort.Strings(units)
+	return units, nil
+}
+
+// show returns properties of given units. Units are reported by systemctl in the given order
+// separated by empty lines.
+func (check *systemdCheck) show(ctx context.Context, units []string) ([]systemdUnit, error) {
+	args := append([]string{"show", "--no-pager", "--property=Id,LoadState,ActiveState,SubState,Result"}, units...)
+	out, err := exec.CommandContext(ctx, SystemctlPath, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to show units: %s", commandError(err))
+	}
+	props := []systemdUnit{}
+	current := systemdUnit{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			if len(current) > 0 {
+				props = append(props, current)
+				current = systemdUnit{}
+			}
+			continue
+		}
+		if kv := strings.SplitN(line, "=", 2); len(kv) == 2 {
+			current[kv[0]] = kv[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read unit properties: %s", err)
+	}
+	if len(current) > 0 {
+		props = append(props, current)
+	}
+	return props, nil
+}
+
+// journal returns last lines of journal of given unit
+func (check *systemdCheck) journal(ctx context.Context, unit string) []string {
+	args := []string{"--unit", unit, "--lines", fmt.Sprintf("%d", check.JournalLines), "--no-pager", "--output=cat"}
+	if check.JournalDirectory != "" {
+		args = append(args, "--directory", check.JournalDirectory)
+	}
+	out, err := exec.CommandContext(ctx, JournalctlPath, args...).Output()
+	if err != nil {
+		return []string{fmt.Sprintf("failed to read journal: %s", commandError(err))}
+	}
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			// '|' would start performance data in the check output
+			lines = append(lines, strings.Replace(line, "|", "/", -1))
+		}
+	}
+	return lines
+}
+
+func (check *systemdCheck) Run(ctx context.Context) (int, string) {
+	units, err := check.units(ctx)
+	if err != nil {
+		return ExitCodeUnknown, nativeOutput("SYSTEMD", ExitCodeUnknown, err.Error())
+	}
+	if len(units) == 0 {
+		status := ExitCodeSuccess
+		if check.Required {
+			status = ExitCodeFailure
+		}
+		return status, nativeOutput("SYSTEMD", status, fmt.Sprintf("no unit matches %s", strings.Join(check.Units, ", ")))
+	}
+	props, err := check.show(ctx, units)
+	if err != nil {
+		return ExitCodeUnknown, nativeOutput("SYSTEMD", ExitCodeUnknown, err.Error())
+	}
+
+	failed := []string{}
+	details := []string{}
+	for _, unit := range props {
+		isFailed := false
+		for _, state := range check.Failed {
+			isFailed = isFailed || unit["ActiveState"] == state
+		}
+		if !isFailed {
+			continue
+		}
+		failed = append(failed, fmt.Sprintf("%s (%s/%s, result: %s)", unit["Id"], unit["ActiveState"], unit["SubState"], unit["Result"]))
+		if check.JournalLines > 0 {
+			for _, line := range check.journal(ctx, unit["Id"]) {
+				details = append(details, fmt.Sprintf("%s: %s", unit["Id"], line))
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return ExitCodeSuccess, nativeOutput("SYSTEMD", ExitCodeSuccess, fmt.Sprintf("%d unit(s) ok", len(props)))
+	}
+	output := nativeOutput("SYSTEMD", ExitCodeFailure, fmt.Sprintf("%d of %d unit(s) failed: %s", len(failed), len(props), strings.Join(failed, ", ")))
+	if len(details) > 0 {
+		output += strings.Join(details, "\n") + "\n"
+	}
+	return ExitCodeFailure, output
+}
+
+// commandError returns error of command execution including its error output, if any
+func commandError(err error) string {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(bytes.TrimSpace(exitErr.Stderr)) > 0 {
+		return fmt.Sprintf("%s: %s", err, bytes.TrimSpace(exitErr.Stderr))
+	}
+	return err.Error()
+}
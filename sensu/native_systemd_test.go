@@ -0,0 +1,137 @@
+package sensu
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+const systemctlStub = `#!/bin/sh
+dir=$(dirname "$0")
+case "$1" in
+list-units)
+	cat "$dir/units"
+	;;
+show)
+	shift
+	first=1
+	for arg in "$@"; do
+		case "$arg" in
+		-*) continue ;;
+		esac
+		[ $first -eq 1 ] || echo
+		first=0
+		cat "$dir/show/$arg"
+	done
+	;;
+*)
+	echo "unknown command $1" >&2
+	exit 1
+	;;
+esac
+`
+
+const journalctlStub = `#!/bin/sh
+echo "$@" >> "$(dirname "$0")/journal.args"
+echo "Starting service..."
+echo "service: fatal error | exiting"
+echo
+`
+
+// fakeSystemd creates stub systemctl and journalctl serving given units and their ActiveState
+func fakeSystemd(t *testing.T, units map[string]string) string {
+	dir := t.TempDir()
+	list := []string{}
+	files := map[string]string{
+		"systemctl":  systemctlStub,
+		"journalctl": journalctlStub,
+	}
+	for unit, state := range units {
+		list = append(list, unit+" loaded "+state+" running Some unit")
+		result := "success"
+		if state == "failed" {
+			result = "exit-code"
+		}
+		files["show/"+unit] = "Id=" + unit + "\nLoadState=loaded\nActiveState=" + state + "\nSubState=" + state + "\nResult=" + result + "\n"
+	}
+	files["units"] = strings.Join(list, "\n") + "\n"
+	writeFiles(t, dir, files)
+	for _, tool := range []string{"systemctl", "journalctl"} {
+		if err := os.Chmod(path.Join(dir, tool), 0755); err != nil {
+			t.Fatalf("failed to make %s executable: %s", tool, err)
+		}
+	}
+	return dir
+}
+
+func TestSystemdCheck(t *testing.T) {
+	defer func(systemctl, journalctl string) {
+		SystemctlPath = systemctl
+		JournalctlPath = journalctl
+	}(SystemctlPath, JournalctlPath)
+	dir := fakeSystemd(t, map[string]string{
+		"sshd.service":       "active",
+		"httpd.service":      "failed",
+		"httpd-init.service": "inactive",
+		"chronyd.service":    "activating",
+		"sshd.socket":        "failed",
+	})
+	SystemctlPath = path.Join(dir, "systemctl")
+	JournalctlPath = path.Join(dir, "journalctl")
+
+	tests := []struct {
+		name     string
+		params   string
+		status   int
+		expected []string
+		absent   []string
+	}{
+		{"ok", `{"units": ["sshd.service"]}`, ExitCodeSuccess, []string{"SYSTEMD OK - 1 unit(s) ok"}, nil},
+		{"pattern", `{"units": ["*.service"], "journal_lines": 0}`, ExitCodeFailure,
+			[]string{"SYSTEMD CRITICAL - 1 of 4 unit(s) failed: httpd.service (failed/failed, result: exit-code)"},
+			[]string{"sshd.socket", "Starting service"}},
+		{"journal", `{"units": ["httpd*"], "journal_lines": 2, "journal_directory": "/var/log/journal/remote"}`, ExitCodeFailure,
+			[]string{"1 of 2 unit(s) failed", "\nhttpd.service: Starting service...\n", "\nhttpd.service: service: fatal error / exiting\n"},
+			[]string{"httpd-init.service:"}},
+		{"multiple patterns", `{"units": ["sshd.*", "chronyd.service"], "failed": ["failed", "activating"], "journal_lines": 0}`, ExitCodeFailure,
+			[]string{"2 of 3 unit(s) failed: chronyd.service (activating/activating, result: success), sshd.socket (failed/failed, result: exit-code)"}, nil},
+		{"no match", `{"units": ["postgresql*"]}`, ExitCodeSuccess, []string{"SYSTEMD OK - no unit matches postgresql*"}, nil},
+		{"required", `{"units": ["postgresql*", "mariadb*"], "required": true}`, ExitCodeFailure, []string{"SYSTEMD CRITICAL - no unit matches postgresql*, mariadb*"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, output := runNativeCheck(t, CheckTypeSystemd, test.params)
+			if status != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, status, output)
+			}
+			for _, expected := range test.expected {
+				if !strings.Contains(output, expected) {
+					t.Errorf("expected %q in output: %s", expected, output)
+				}
+			}
+			for _, absent := range test.absent {
+				if strings.Contains(output, absent) {
+					t.Errorf("unexpected %q in output: %s", absent, output)
+				}
+			}
+		})
+	}
+
+	args, err := ioutil.ReadFile(path.Join(dir, "journal.args"))
+	if err != nil {
+		t.Fatalf("failed to read journalctl arguments: %s", err)
+	}
+	if expected := "--unit httpd.service --lines 2 --no-pager --output=cat --directory /var/log/journal/remote\n"; string(args) != expected {
+		t.Errorf("expected journalctl to be called once with %q, got %q", expected, args)
+	}
+
+	t.Run("unavailable", func(t *testing.T) {
+		SystemctlPath = path.Join(dir, "missing")
+		status, output := runNativeCheck(t, CheckTypeSystemd, `{"units": ["*"]}`)
+		if status != ExitCodeUnknown || !strings.Contains(output, "failed to list units") {
+			t.Errorf("expected unknown status when systemctl fails, got %d: %s", status, output)
+		}
+	})
+}
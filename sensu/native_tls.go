@@ -0,0 +1,199 @@
+package sensu
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"strings"
+	"time"
+)
+
+//CheckTypeTLS is native check type verifying expiration of TLS certificates
+const CheckTypeTLS = "tls"
+
+func init() {
+	RegisterNativeCheck(CheckTypeTLS, newTLSCheck)
+}
+
+// certSource is certificate chain together with its origin
+type certSource struct {
+	origin string
+	chain  []*x509.Certificate
+}
+
+// tlsCheck verifies certificates from PEM files and TLS endpoints
+type tlsCheck struct {
+	Files      []string `json:"files"`
+	Endpoints  []string `json:"endpoints"`
+	ServerName string   `json:"server_name"`
+	Names      []string `json:"names"`
+	Verify     bool     `json:"verify"`
+	CAFile     string   `json:"ca_file"`
+	Warning    float64  `json:"warning"`
+	Critical   float64  `json:"critical"`
+}
+
+func newTLSCheck(params json.RawMessage) (NativeCheck, error) {
+	check := tlsCheck{Warning: 30, Critical: 7}
+	if err := decodeParams(params, &check); err != nil {
+		return nil, err
+	}
+	if len(check.Files) == 0 && len(check.Endpoints) == 0 {
+		return nil, fmt.Errorf("missing certificate files or endpoints")
+	}
+	for _, endpoint := range check.Endpoints {
+		if _, _, err := net.SplitHostPort(endpoint); err != nil {
+			return nil, fmt.Errorf("invalid endpoint %s: %s", endpoint, err)
+		}
+	}
+	if check.Warning < 0 || check.Critical < 0 {
+		return nil, fmt.Errorf("thresholds have to be number of days")
+	}
+	return &check, nil
+}
+
+// loadCertificates returns certificates from given PEM file, the first one is expected to be the leaf
+func loadCertificates(filePath string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return certs, nil
+}
+
+// fetch returns certificate chain presented by given TLS endpoint
+func (check *tlsCheck) fetch(ctx context.Context, endpoint string) ([]*x509.Certificate, error) {
+	host, _, _ := net.SplitHostPort(endpoint)
+	serverName := check.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// chain is verified separately, so that expired certificates can be reported properly
+	client := tls.Client(conn, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	if err := client.Handshake(); err != nil {
+		return nil, err
+	}
+	return client.ConnectionState().PeerCertificates, nil
+}
+
+// verify verifies certificate chain against system or configured CA certificates and names of the check
+func (check *tlsCheck) verify(source certSource, roots *x509.CertPool) error {
+	leaf := source.chain[0]
+	if check.Verify {
+		intermediates := x509.NewCertPool()
+		for _, cert := range source.chain[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		// expiration is reported according to thresholds
+		if invalid, ok := err.(x509.CertificateInvalidError); err != nil && (!ok || invalid.Reason != x509.Expired) {
+			return err
+		}
+	}
+	for _, name := range check.Names {
+		if err := leaf.VerifyHostname(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (check *tlsCheck) Run(ctx context.Context) (int, string) {
+	var roots *x509.CertPool
+	if check.CAFile != "" {
+		cas, err := loadCertificates(check.CAFile)
+		if err != nil {
+			return ExitCodeUnknown, nativeOutput("TLS", ExitCodeUnknown, fmt.Sprintf("failed to load CA file %s: %s", check.CAFile, err))
+		}
+		roots = x509.NewCertPool()
+		for _, ca := range cas {
+			roots.AddCert(ca)
+		}
+	}
+
+	sources := []certSource{}
+	problems := []string{}
+	for _, filePath := range check.Files {
+		chain, err := loadCertificates(filePath)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", filePath, err))
+			continue
+		}
+		sources = append(sources, certSource{origin: filePath, chain: chain})
+	}
+	for _, endpoint := range check.Endpoints {
+		chain, err := check.fetch(ctx, endpoint)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", endpoint, err))
+			continue
+		}
+		sources = append(sources, certSource{origin: endpoint, chain: chain})
+	}
+
+	var soonest *x509.Certificate
+	soonestOrigin := ""
+	for _, source := range sources {
+		if err := check.verify(source, roots); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", source.origin, err))
+		}
+		for _, cert := range source.chain {
+			if soonest == nil || cert.NotAfter.Before(soonest.NotAfter) {
+				soonest, soonestOrigin = cert, source.origin
+			}
+		}
+	}
+	if soonest == nil {
+		return ExitCodeFailure, nativeOutput("TLS", ExitCodeFailure, strings.Join(problems, ", "))
+	}
+
+	remaining := time.Until(soonest.NotAfter)
+	days := math.Trunc(remaining.Hours() / 24)
+	status := thresholdStatus(days, check.Warning, check.Critical, false)
+	message := fmt.Sprintf("certificate %s from %s expires in %.0f day(s) (%s)",
+		soonest.Subject.String(), soonestOrigin, days, soonest.NotAfter.UTC().Format(time.RFC3339))
+	if remaining < 0 {
+		status = ExitCodeFailure
+		message = fmt.Sprintf("certificate %s from %s expired %.0f day(s) ago (%s)",
+			soonest.Subject.String(), soonestOrigin, -days, soonest.NotAfter.UTC().Format(time.RFC3339))
+	}
+	if len(problems) > 0 {
+		status = ExitCodeFailure
+		message = fmt.Sprintf("%s; %s", strings.Join(problems, ", "), message)
+	}
+	return status, nativeOutput("TLS", status, message,
+		fmt.Sprintf("days=%.0f;%s;%s", days, formatThreshold(check.Warning), formatThreshold(check.Critical)))
+}
@@ -0,0 +1,140 @@
+package sensu
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert is generated certificate together with its private key
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert generates certificate valid for localhost expiring after given duration. Certificate
+// is self-signed if parent is nil.
+func newTestCert(t *testing.T, name string, expires time.Duration, parent *testCert) testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		// extra hour keeps the number of remaining days stable during the test
+		NotAfter:    time.Now().Add(expires + time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+	return testCert{cert: cert, key: key}
+}
+
+// writePEM writes given certificates to PEM file in given directory
+func writePEM(t *testing.T, dir string, name string, certs ...testCert) string {
+	data := []byte{}
+	for _, cert := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.cert.Raw})...)
+	}
+	filePath := path.Join(dir, name)
+	if err := ioutil.WriteFile(filePath, data, 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", filePath, err)
+	}
+	return filePath
+}
+
+// tlsServer starts TLS server presenting given certificate and returns its endpoint
+func tlsServer(t *testing.T, cert testCert) string {
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{cert.cert.Raw}, PrivateKey: cert.key}}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server.Listener.Addr().String()
+}
+
+func TestTLSCheck(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "Test CA", 365*24*time.Hour, nil)
+	caFile := writePEM(t, dir, "ca.pem", ca)
+	valid := newTestCert(t, "valid", 60*24*time.Hour, &ca)
+	expiring := newTestCert(t, "expiring", 20*24*time.Hour, &ca)
+	imminent := newTestCert(t, "imminent", 3*24*time.Hour, &ca)
+	expired := newTestCert(t, "expired", -3*24*time.Hour, &ca)
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+
+	tests := []struct {
+		name   string
+		params string
+		status int
+		output string
+		days   string
+	}{
+		{"ok", fmt.Sprintf(`{"endpoints": [%q], "verify": true, "ca_file": %q, "names": ["localhost"]}`, tlsServer(t, valid), caFile),
+			ExitCodeSuccess, "TLS OK - certificate CN=valid from 127.0.0.1:", "60"},
+		{"warning", fmt.Sprintf(`{"endpoints": [%q], "verify": true, "ca_file": %q}`, tlsServer(t, expiring), caFile),
+			ExitCodeWarning, "TLS WARNING - certificate CN=expiring", "20"},
+		{"critical", fmt.Sprintf(`{"endpoints": [%q], "warning": 10, "critical": 5}`, tlsServer(t, imminent)),
+			ExitCodeFailure, "TLS CRITICAL - certificate CN=imminent", "3"},
+		{"expired", fmt.Sprintf(`{"endpoints": [%q], "verify": true, "ca_file": %q}`, tlsServer(t, expired), caFile),
+			ExitCodeFailure, "certificate CN=expired from 127.0.0.1:", "-2"},
+		{"soonest file", fmt.Sprintf(`{"files": [%q, %q]}`, writePEM(t, dir, "valid.pem", valid, ca), writePEM(t, dir, "expiring.pem", expiring)),
+			ExitCodeWarning, "certificate CN=expiring from " + path.Join(dir, "expiring.pem"), "20"},
+		{"unknown authority", fmt.Sprintf(`{"endpoints": [%q], "verify": true}`, tlsServer(t, valid)),
+			ExitCodeFailure, "certificate signed by unknown authority", "60"},
+		{"name mismatch", fmt.Sprintf(`{"files": [%q], "names": ["example.com"]}`, path.Join(dir, "valid.pem")),
+			ExitCodeFailure, "not example.com", "60"},
+		{"handshake failure", fmt.Sprintf(`{"endpoints": [%q]}`, plain.Listener.Addr().String()),
+			ExitCodeFailure, "TLS CRITICAL - " + plain.Listener.Addr().String() + ": ", ""},
+		{"missing file", fmt.Sprintf(`{"files": [%q]}`, path.Join(dir, "missing.pem")),
+			ExitCodeFailure, "no such file or directory", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, output := runNativeCheck(t, CheckTypeTLS, test.params)
+			if status != test.status || !strings.Contains(output, test.output) {
+				t.Errorf("expected status %d with %q, got %d: %s", test.status, test.output, status, output)
+			}
+			_, metrics := ParsePerfdata(output)
+			if test.days == "" {
+				if len(metrics) != 0 {
+					t.Errorf("expected no perfdata, got: %s", output)
+				}
+			} else if len(metrics) != 1 || metrics[0].Name != "days" || fmt.Sprintf("%g", metrics[0].Value) != test.days {
+				t.Errorf("expected %s days in perfdata, got: %s", test.days, output)
+			}
+		})
+	}
+}
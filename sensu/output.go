@@ -0,0 +1,63 @@
+package sensu
+
+import (
+	"fmt"
+)
+
+//LimitedBuffer is io.Writer which keeps at most given number of bytes of the written data.
+//When the limit is exceeded, the beginning and the end of the data are kept and the rest is dropped,
+//so memory consumption stays bounded regardless of the amount of written data.
+type LimitedBuffer struct {
+	limit int
+	head  []byte
+	tail  []byte
+	total int64
+}
+
+//NewLimitedBuffer creates buffer with given size limit. Non-positive limit means unlimited buffer.
+func NewLimitedBuffer(limit int) *LimitedBuffer {
+	return &LimitedBuffer{limit: limit}
+}
+
+//Write stores given data to buffer
+func (buf *LimitedBuffer) Write(data []byte) (int, error) {
+	written := len(data)
+	buf.total += int64(written)
+	if buf.limit <= 0 {
+		buf.head = append(buf.head, data...)
+		return written, nil
+	}
+
+	headLimit := buf.limit / 2
+	tailLimit := buf.limit - headLimit
+	if free := headLimit - len(buf.head); free > 0 {
+		if free > len(data) {
+			free = len(data)
+		}
+		buf.head = append(buf.head, data[:free]...)
+		data = data[free:]
+	}
+	if len(data) >= tailLimit {
+		buf.tail = append(buf.tail[:0], data[len(data)-tailLimit:]...)
+	} else if len(data) > 0 {
+		buf.tail = append(buf.tail, data...)
+		if len(buf.tail) > tailLimit {
+			buf.tail = buf.tail[len(buf.tail)-tailLimit:]
+		}
+	}
+	return written, nil
+}
+
+//Truncated returns true if some of the written data was dropped
+func (buf *LimitedBuffer) Truncated() bool {
+	return buf.total > int64(len(buf.head)+len(buf.tail))
+}
+
+//String returns stored data. Dropped data is replaced with truncation marker.
+func (buf *LimitedBuffer) String() string {
+	if !buf.Truncated() {
+		return string(buf.head) + string(buf.tail)
+	}
+	dropped := buf.total - int64(len(buf.head)+len(buf.tail))
+	return fmt.Sprintf("%s\n[... %d bytes truncated ...]\n%s", buf.head, dropped, buf.tail)
+}
@@ -0,0 +1,52 @@
+package sensu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLimitedBuffer(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		writes    []string
+		output    string
+		truncated bool
+	}{
+		{"unlimited", 0, []string{"hello ", "world"}, "hello world", false},
+		{"within limit", 11, []string{"hello ", "world"}, "hello world", false},
+		{"single write", 6, []string{"hello world"}, "hel\n[... 5 bytes truncated ...]\nrld", true},
+		{"small writes", 6, []string{"he", "llo", " w", "or", "ld"}, "hel\n[... 5 bytes truncated ...]\nrld", true},
+		{"large tail write", 6, []string{"hel", "lo wo", "rld"}, "hel\n[... 5 bytes truncated ...]\nrld", true},
+		{"odd limit", 5, []string{"hello world"}, "he\n[... 6 bytes truncated ...]\nrld", true},
+		{"empty", 6, []string{}, "", false},
+	}
+	for _, test := range tests {
+		buf := NewLimitedBuffer(test.limit)
+		for _, data := range test.writes {
+			if written, err := buf.Write([]byte(data)); err != nil || written != len(data) {
+				t.Errorf("%s: expected %d bytes to be written, got %d: %v", test.name, len(data), written, err)
+			}
+		}
+		if output := buf.String(); output != test.output {
+			t.Errorf("%s: expected %q, got %q", test.name, test.output, output)
+		}
+		if buf.Truncated() != test.truncated {
+			t.Errorf("%s: expected truncated %t, got %t", test.name, test.truncated, buf.Truncated())
+		}
+	}
+}
+
+func TestLimitedBufferBounded(t *testing.T) {
+	buf := NewLimitedBuffer(64)
+	chunk := []byte(strings.Repeat("x", 1000))
+	for i := 0; i < 1000; i++ {
+		buf.Write(chunk)
+	}
+	if size := cap(buf.head) + cap(buf.tail); size > 4*64 {
+		t.Errorf("expected buffer to stay bounded, it holds %d bytes", size)
+	}
+	if !strings.Contains(buf.String(), "[... 999936 bytes truncated ...]") {
+		t.Errorf("unexpected truncation marker: %q", buf.String())
+	}
+}
@@ -0,0 +1,112 @@
+package sensu
+
+import (
+	"sync"
+)
+
+//Policies applied to requests of checks which are still being executed
+const (
+	OverlapSkip  = "skip"
+	OverlapQueue = "queue"
+	OverlapAllow = "allow"
+)
+
+//OverlapPolicies lists supported overlap policies
+var OverlapPolicies = []string{OverlapSkip, OverlapQueue, OverlapAllow}
+
+//Tracker tracks checks in execution by name and prevents overlapping executions of the same check
+//according to the policy. With skip policy requests of running checks are dropped, with queue policy
+//single request is postponed until the running execution finishes and allow policy does not prevent
+//overlapping at all.
+type Tracker struct {
+	Policy  string
+	lock    sync.Mutex
+	running map[string]int
+	queued  map[string]Request
+	skipped map[string]int
+	total   map[string]int
+}
+
+//NewTracker creates tracker with given overlap policy
+func NewTracker(policy string) *Tracker {
+	return &Tracker{
+		Policy:  policy,
+		running: make(map[string]int),
+		queued:  make(map[string]Request),
+		skipped: make(map[string]int),
+		total:   make(map[string]int),
+	}
+}
+
+// skip counts skipped execution of given check. Has to be called with lock held.
+func (self *Tracker) skip(name string) {
+	self.skipped[name]++
+	self.total[name]++
+}
+
+//Acquire marks requested check as running and returns true if it can be executed now. Otherwise the request
+//is either queued, in which case second returned value is true, or skipped. Queued request replaces previously
+//queued one, which is then counted as skipped.
+func (self *Tracker) Acquire(request Request) (bool, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.running[request.Name] == 0 || self.Policy == OverlapAllow {
+		self.running[request.Name]++
+		return true, false
+	}
+	if self.Policy == OverlapQueue {
+		_, replaced := self.queued[request.Name]
+		self.queued[request.Name] = request
+		if !replaced {
+			return false, true
+		}
+	}
+	self.skip(request.Name)
+	return false, self.Policy == OverlapQueue
+}
+
+//Skipped returns total number of skipped executions of given check
+func (self *Tracker) Skipped(name string) int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.total[name]
+}
+
+//Release marks execution of given check as finished. Returns queued request of the check if there is any,
+//in which case the check remains running and the request has to be executed and released afterwards.
+func (self *Tracker) Release(name string) *Request {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if request, ok := self.queued[name]; ok {
+		delete(self.queued, name)
+		return &request
+	}
+	if self.running[name]--; self.running[name] <= 0 {
+		delete(self.running, name)
+	}
+	return nil
+}
+
+//Annotate adds number of executions skipped since previous result of the check to the given result
+//as annotation and total number of skipped executions as metric. Annotation is added only to results
+//preceded by skipped executions and nothing is added to results of checks which were never skipped.
+func (self *Tracker) Annotate(result *Result) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	name := result.Result.Name
+	if self.total[name] == 0 {
+		return
+	}
+	if self.skipped[name] > 0 {
+		if result.Annotations == nil {
+			result.Annotations = make(map[string]interface{})
+		}
+		result.Annotations[AnnotationSkipped] = self.skipped[name]
+		delete(self.skipped, name)
+	}
+	result.Metrics = append(result.Metrics, Metric{
+		Name:      AnnotationSkipped,
+		Value:     float64(self.total[name]),
+		Timestamp: result.Result.Executed,
+	})
+}
@@ -0,0 +1,100 @@
+package sensu
+
+import (
+	"reflect"
+	"testing"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+)
+
+func TestTracker(t *testing.T) {
+	request := func(issued int64) Request {
+		return Request{CheckRequest: connector.CheckRequest{Name: "check", Issued: issued}}
+	}
+	type acquire struct {
+		execute bool
+		queued  bool
+	}
+	tests := []struct {
+		policy   string
+		acquires []acquire
+		released []int64
+		skipped  int
+	}{
+		{OverlapAllow, []acquire{{true, false}, {true, false}, {true, false}}, []int64{}, 0},
+		{OverlapSkip, []acquire{{true, false}, {false, false}, {false, false}}, []int64{}, 2},
+		// the last request replaces the queued one, which is then skipped
+		{OverlapQueue, []acquire{{true, false}, {false, true}, {false, true}}, []int64{3}, 1},
+	}
+	for _, test := range tests {
+		tracker := NewTracker(test.policy)
+		executing := 0
+		for idx, expected := range test.acquires {
+			execute, queued := tracker.Acquire(request(int64(idx + 1)))
+			if execute != expected.execute || queued != expected.queued {
+				t.Errorf("%s: request %d expected execute %t and queued %t, got %t and %t", test.policy, idx+1, expected.execute, expected.queued, execute, queued)
+			}
+			if execute {
+				executing++
+			}
+		}
+		released := []int64{}
+		for ; executing > 0; executing-- {
+			for next := tracker.Release("check"); next != nil; next = tracker.Release("check") {
+				released = append(released, next.Issued)
+			}
+		}
+		if !reflect.DeepEqual(released, test.released) {
+			t.Errorf("%s: expected queued requests %v, got %v", test.policy, test.released, released)
+		}
+		if skipped := tracker.Skipped("check"); skipped != test.skipped {
+			t.Errorf("%s: expected %d skipped executions, got %d", test.policy, test.skipped, skipped)
+		}
+		if execute, _ := tracker.Acquire(request(10)); !execute {
+			t.Errorf("%s: expected check to be executed once all executions finished", test.policy)
+		}
+	}
+}
+
+func TestTrackerAnnotate(t *testing.T) {
+	tracker := NewTracker(OverlapSkip)
+	result := func() Result {
+		result := Result{CheckResult: connector.CheckResult{Result: connector.Result{Name: "check", Executed: 1600000000}}}
+		tracker.Annotate(&result)
+		return result
+	}
+	skip := func(count int) {
+		for idx := 0; idx <= count; idx++ {
+			tracker.Acquire(Request{CheckRequest: connector.CheckRequest{Name: "check"}})
+		}
+		tracker.Release("check")
+	}
+
+	if annotated := result(); len(annotated.Annotations) != 0 || len(annotated.Metrics) != 0 {
+		t.Errorf("expected nothing without skipped executions, got %v and metrics %v", annotated.Annotations, annotated.Metrics)
+	}
+	tests := []struct {
+		skipped    int
+		annotation interface{}
+		total      float64
+	}{
+		{2, 2, 2},
+		// total is reported even when no execution was skipped since the previous result
+		{0, nil, 2},
+		{1, 1, 3},
+	}
+	for idx, test := range tests {
+		skip(test.skipped)
+		annotated := result()
+		if annotated.Annotations[AnnotationSkipped] != test.annotation {
+			t.Errorf("result %d: expected %v skipped executions in annotation, got %v", idx+1, test.annotation, annotated.Annotations)
+		}
+		expected := []Metric{{Name: AnnotationSkipped, Value: test.total, Timestamp: 1600000000}}
+		if !reflect.DeepEqual(annotated.Metrics, expected) {
+			t.Errorf("result %d: expected metrics %v, got %v", idx+1, expected, annotated.Metrics)
+		}
+	}
+	if skipped := tracker.Skipped("check"); skipped != 3 {
+		t.Errorf("expected 3 skipped executions in total, got %d", skipped)
+	}
+}
@@ -0,0 +1,107 @@
+package sensu
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Metric holds single sample parsed from check output
+type Metric struct {
+	Name      string            `json:"name"`
+	Value     float64           `json:"value"`
+	Timestamp int64             `json:"timestamp"`
+	Unit      string            `json:"unit,omitempty"`
+	Warning   string            `json:"warning,omitempty"`
+	Critical  string            `json:"critical,omitempty"`
+	Min       string            `json:"min,omitempty"`
+	Max       string            `json:"max,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+var perfdataValueRegexp = regexp.MustCompile(`^([-+]?[0-9]*\.?[0-9]+(?:[eE][-+]?[0-9]+)?)([a-zA-Z%]*)$`)
+
+// splitPerfdata splits perfdata string to single label=value tokens. Labels can be quoted
+// with single quotes and quote itself is then escaped by doubling it.
+func splitPerfdata(perfdata string) []string {
+	tokens := []string{}
+	var token strings.Builder
+	quoted := false
+	for i := 0; i < len(perfdata); i++ {
+		c := perfdata[i]
+		switch {
+		case c == '\'' && quoted && i+1 < len(perfdata) && perfdata[i+1] == '\'':
+			token.WriteByte(c)
+			i++
+		case c == '\'':
+			quoted = !quoted
+		case (c == ' ' || c == '\t' || c == '\n' || c == '\r') && !quoted:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteByte(c)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+func parsePerfdataToken(token string) (Metric, bool) {
+	eq := strings.LastIndex(token, "=")
+	if eq < 1 {
+		return Metric{}, false
+	}
+	fields := strings.Split(token[eq+1:], ";")
+	match := perfdataValueRegexp.FindStringSubmatch(fields[0])
+	if match == nil {
+		// undetermined ("U") or otherwise invalid value
+		return Metric{}, false
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return Metric{}, false
+	}
+	metric := Metric{Name: token[:eq], Value: value, Unit: match[2]}
+	for idx, target := range []*string{&metric.Warning, &metric.Critical, &metric.Min, &metric.Max} {
+		if idx+1 < len(fields) {
+			*target = fields[idx+1]
+		}
+	}
+	return metric, true
+}
+
+//ParsePerfdata splits Nagios plugin output to human-readable text and performance data.
+//Perfdata is expected after '|' on the first line and after '|' on any line of long text,
+//in which case all following lines are perfdata too. Invalid perfdata items are skipped.
+func ParsePerfdata(output string) (string, []Metric) {
+	lines := strings.Split(output, "\n")
+	text := []string{}
+	perfdata := []string{}
+	for idx, line := range lines {
+		parts := strings.SplitN(line, "|", 2)
+		if idx == 0 || len(parts) == 1 {
+			text = append(text, strings.TrimRight(parts[0], " "))
+			if len(parts) == 2 {
+				perfdata = append(perfdata, parts[1])
+			}
+			continue
+		}
+		// long text perfdata continues till the end of output
+		text = append(text, strings.TrimRight(parts[0], " "))
+		perfdata = append(perfdata, parts[1])
+		perfdata = append(perfdata, lines[idx+1:]...)
+		break
+	}
+
+	metrics := []Metric{}
+	for _, token := range splitPerfdata(strings.Join(perfdata, " ")) {
+		if metric, ok := parsePerfdataToken(token); ok {
+			metrics = append(metrics, metric)
+		}
+	}
+	return strings.Join(text, "\n"), metrics
+}
@@ -0,0 +1,81 @@
+package sensu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePerfdata(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		text    string
+		metrics []Metric
+	}{
+		{
+			name:    "no perfdata",
+			output:  "OK - all fine",
+			text:    "OK - all fine",
+			metrics: []Metric{},
+		},
+		{
+			name:   "uom and thresholds",
+			output: "DISK OK - free space: / 3326 MB | /=2643MB;5948;5958;0;5968 time=0.5s;;;; used=93%",
+			text:   "DISK OK - free space: / 3326 MB",
+			metrics: []Metric{
+				{Name: "/", Value: 2643, Unit: "MB", Warning: "5948", Critical: "5958", Min: "0", Max: "5968"},
+				{Name: "time", Value: 0.5, Unit: "s"},
+				{Name: "used", Value: 93, Unit: "%"},
+			},
+		},
+		{
+			name:   "threshold ranges",
+			output: "LOAD OK|load1=0.48;@10:20;~:30;0; load5=-1.5e-2;10:;",
+			text:   "LOAD OK",
+			metrics: []Metric{
+				{Name: "load1", Value: 0.48, Warning: "@10:20", Critical: "~:30", Min: "0"},
+				{Name: "load5", Value: -0.015, Warning: "10:"},
+			},
+		},
+		{
+			name:   "undetermined and invalid values",
+			output: "UNKNOWN|a=U;1;2 b=abc c= =1 d=2c",
+			text:   "UNKNOWN",
+			metrics: []Metric{
+				{Name: "d", Value: 2, Unit: "c"},
+			},
+		},
+		{
+			name:   "quoted labels",
+			output: "OK | 'disk usage'=10MB 'it''s'=1 'a=b'=2",
+			text:   "OK",
+			metrics: []Metric{
+				{Name: "disk usage", Value: 10, Unit: "MB"},
+				{Name: "it's", Value: 1},
+				{Name: "a=b", Value: 2},
+			},
+		},
+		{
+			name:   "long text",
+			output: "OK - first | a=1\nsecond line\nthird line | b=2\nc=3\nd=4",
+			text:   "OK - first\nsecond line\nthird line",
+			metrics: []Metric{
+				{Name: "a", Value: 1},
+				{Name: "b", Value: 2},
+				{Name: "c", Value: 3},
+				{Name: "d", Value: 4},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, metrics := ParsePerfdata(test.output)
+			if text != test.text {
+				t.Errorf("expected text %q, got %q", test.text, text)
+			}
+			if !reflect.DeepEqual(metrics, test.metrics) {
+				t.Errorf("expected metrics %+v, got %+v", test.metrics, metrics)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package sensu
+
+import (
+	"syscall"
+)
+
+//Sandbox modes
+const (
+	SandboxNone   = "none"
+	SandboxRemote = "remote"
+	SandboxAll    = "all"
+)
+
+// sandboxRootDir is the directory in executor's temporary directory used as sandbox root
+const sandboxRootDir = "sandbox"
+
+//SandboxSpec describes sandbox in which the check command is executed. The command runs in its own
+//mount, PID and IPC namespaces (and optionally network namespace) with root filesystem consisting
+//of read-only bind mounts of given paths, fresh /proc, minimal /dev and empty writable /tmp.
+type SandboxSpec struct {
+	Root       string              `json:"root"`
+	Binds      []string            `json:"binds"`
+	Network    bool                `json:"network"`
+	Credential *syscall.Credential `json:"credential,omitempty"`
+}
+
+// sandboxed returns true if the requested check should be executed in sandbox. Checks can opt out or in
+// explicitly, otherwise sandbox mode decides. Checks are considered remote unless the command is defined locally.
+func (self *Executor) sandboxed(check Check) bool {
+	if check.Sandbox != nil {
+		return *check.Sandbox
+	}
+	switch self.SandboxMode {
+	case SandboxAll:
+		return true
+	case SandboxRemote:
+		return check.remote
+	}
+	return false
+}
@@ -1,7 +1,6 @@
 package sensu
 
 import (
-	"encoding/json"
 	"reflect"
 	"time"
 
@@ -10,31 +9,21 @@ import (
 	"github.com/infrawatch/apputils/logging"
 )
 
-// Check holds data for single Sensu check to be scheduled
-type Check struct {
-	Command      string   `json:"command"`
-	Subscribers  []string `json:"subscribers"`
-	Interval     int      `json:"interval"`
-	Timeout      int      `json:"timeout"`
-	TTL          int      `json:"ttl"`
-	TTLStatus    int      `json:"ttl_status"`
-	Occurrences  int      `json:"occurrences"`
-	Refresh      int      `json:"refresh"`
-	Handlers     []string `json:"handlers"`
-	Dependencies []string `json:"dependencies"`
-}
-
-// Scheduler holds data for scheduling standaline checks
+// Scheduler holds data for scheduling standaline checks. Checks with known time of last execution
+// are scheduled relative to it, others are first executed after their interval passes.
 type Scheduler struct {
-	Checks map[string]Check
-	log    *logging.Logger
+	Checks       map[string]Check
+	LastExecuted map[string]time.Time
+	log          *logging.Logger
 }
 
 // NewScheduler creates Sensu standalone check scheduler according to configuration
 func NewScheduler(cfg *config.INIConfig, logger *logging.Logger) (*Scheduler, error) {
 	var scheduler Scheduler
+	var err error
 	scheduler.log = logger
-	err := json.Unmarshal(cfg.Sections["sensu"].Options["checks"].GetBytes(), &scheduler.Checks)
+	scheduler.LastExecuted = make(map[string]time.Time)
+	scheduler.Checks, err = LoadChecks(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -53,9 +42,18 @@ func (sched *Scheduler) Start(outchan chan interface{}) {
 			continue
 		}
 		//TODO: use rather time.NewTicker() to be able to ticker.Stop() all tickers in Scheduler.Stop()
+		interval := time.Duration(data.Interval) * time.Second
+		delay := interval
+		if executed, ok := sched.LastExecuted[name]; ok {
+			delay = time.Until(executed.Add(interval))
+		}
+		if delay > interval {
+			// last execution in future means the clock was set back
+			delay = interval
+		}
 		cases = append(cases, reflect.SelectCase{
 			Dir:  reflect.SelectRecv,
-			Chan: reflect.ValueOf(time.Tick(time.Duration(data.Interval) * time.Second)),
+			Chan: reflect.ValueOf(tick(delay, interval)),
 		})
 		checks = append(checks, name)
 	}
@@ -66,11 +64,30 @@ func (sched *Scheduler) Start(outchan chan interface{}) {
 			// request check execution
 			sched.log.Metadata(map[string]interface{}{"check": checks[index]})
 			sched.log.Debug("Requesting execution of check.")
-			outchan <- connector.CheckRequest{
+			outchan <- Request{CheckRequest: connector.CheckRequest{
 				Command: sched.Checks[checks[index]].Command,
 				Name:    checks[index],
 				Issued:  time.Now().Unix(),
-			}
+			}}
+		}
+	}()
+}
+
+// tick returns channel ticking with given interval, first tick comes after given delay. Overdue
+// first tick comes immediately.
+func tick(delay time.Duration, interval time.Duration) <-chan time.Time {
+	if delay == interval {
+		return time.Tick(interval)
+	}
+	ticks := make(chan time.Time)
+	go func() {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		ticks <- time.Now()
+		for now := range time.Tick(interval) {
+			ticks <- now
 		}
 	}()
+	return ticks
 }
@@ -0,0 +1,170 @@
+package sensu
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// stateStoreFile is file in StateDir holding runtime state of checks
+const stateStoreFile = "checks.json"
+
+//StateFlushInterval is interval in which changed state of checks should be flushed to StateDir
+const StateFlushInterval = 10 * time.Second
+
+//RemoteStateExpiration is time after which state of remote check is dropped when the check was not executed.
+//Remote checks are requested by Sensu server, so the agent cannot tell whether they still exist otherwise.
+const RemoteStateExpiration = 24 * time.Hour
+
+//StoredCheck is runtime state of single check persisted between agent restarts. Published holds time
+//of the last result published by ChangeFilter and Pending is set when the last status was not published yet.
+type StoredCheck struct {
+	Executed  int64 `json:"executed"`
+	Status    int   `json:"status"`
+	Remote    bool  `json:"remote,omitempty"`
+	Published int64 `json:"published,omitempty"`
+	Pending   bool  `json:"pending,omitempty"`
+	CheckState
+}
+
+//StateStore keeps runtime state of checks by name and persists it in StateDir, so that scheduling,
+//history and flap detection continue after restart of the agent. Changes are kept in memory until
+//Flush is called, state is kept only in memory when StateDir is empty.
+type StateStore struct {
+	lock      sync.Mutex
+	flushLock sync.Mutex
+	dirty     bool
+	checks    map[string]StoredCheck
+}
+
+//LoadStateStore creates state store containing state persisted in StateDir. Returned store is usable
+//even when error is returned, it is empty in such case.
+func LoadStateStore() (*StateStore, error) {
+	store := StateStore{checks: make(map[string]StoredCheck)}
+	if StateDir == "" {
+		return &store, nil
+	}
+	data, err := ioutil.ReadFile(path.Join(StateDir, stateStoreFile))
+	if os.IsNotExist(err) {
+		return &store, nil
+	} else if err != nil {
+		return &store, err
+	}
+	if err := json.Unmarshal(data, &store.checks); err != nil {
+		store.checks = make(map[string]StoredCheck)
+		return &store, err
+	}
+	return &store, nil
+}
+
+// writeStateFile replaces file in state directory atomically, so that crash does not leave corrupted
+// or empty state behind. Data are synced to disk before the file is replaced.
+func writeStateFile(filePath string, data []byte) error {
+	tmp, err := os.OpenFile(filePath+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(filePath+".tmp", filePath); err != nil {
+		return err
+	}
+	// rename itself is durable only once the directory is synced
+	dir, err := os.Open(path.Dir(filePath))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+//Checks returns copy of stored state of all checks
+func (self *StateStore) Checks() map[string]StoredCheck {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	checks := make(map[string]StoredCheck, len(self.checks))
+	for name, check := range self.checks {
+		checks[name] = check
+	}
+	return checks
+}
+
+//Prune drops state of local checks which are not in given check definitions any more and state of remote
+//checks which were not executed for RemoteStateExpiration
+func (self *StateStore) Prune(checks map[string]Check) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	expired := time.Now().Add(-RemoteStateExpiration).Unix()
+	for name, check := range self.checks {
+		if _, ok := checks[name]; ok {
+			continue
+		}
+		if !check.Remote || check.Executed < expired {
+			delete(self.checks, name)
+			self.dirty = true
+		}
+	}
+}
+
+//Flush persists state of all checks to StateDir when it changed since the last flush
+func (self *StateStore) Flush() error {
+	self.flushLock.Lock()
+	defer self.flushLock.Unlock()
+	self.lock.Lock()
+	if !self.dirty || StateDir == "" {
+		self.lock.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(self.checks)
+	self.dirty = false
+	self.lock.Unlock()
+	if err == nil {
+		if err = os.MkdirAll(StateDir, 0700); err == nil {
+			err = writeStateFile(path.Join(StateDir, stateStoreFile), data)
+		}
+	}
+	if err != nil {
+		// state is written again by the next flush
+		self.lock.Lock()
+		self.dirty = true
+		self.lock.Unlock()
+	}
+	return err
+}
+
+//Record stores execution time, status and check state of given result. Result is expected to be
+//recorded in History already.
+func (self *StateStore) Record(result Result) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	check := self.checks[result.Result.Name]
+	check.Executed = result.Result.Executed
+	check.Status = result.Result.Status
+	check.Remote = result.Definition.remote
+	check.CheckState = result.State
+	self.checks[result.Result.Name] = check
+	self.dirty = true
+}
+
+//RecordPublished stores publication state of given check
+func (self *StateStore) RecordPublished(name string, published int64, pending bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	check := self.checks[name]
+	check.Published = published
+	check.Pending = pending
+	self.checks[name] = check
+	self.dirty = true
+}
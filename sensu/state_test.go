@@ -0,0 +1,60 @@
+package sensu
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+)
+
+func TestStateStore(t *testing.T) {
+	defer func(dir string) { StateDir = dir }(StateDir)
+	StateDir = t.TempDir()
+
+	store, err := LoadStateStore()
+	if err != nil {
+		t.Fatalf("failed to load store: %s", err)
+	}
+	now := time.Now().Unix()
+	for _, result := range []struct {
+		name     string
+		executed int64
+		remote   bool
+	}{
+		{"local", now, false},
+		{"removed", now, false},
+		{"remote", now, true},
+		{"expired", now - int64(RemoteStateExpiration/time.Second) - 1, true},
+	} {
+		store.Record(Result{
+			CheckResult: connector.CheckResult{Result: connector.Result{Name: result.name, Executed: result.executed, Status: ExitCodeWarning}},
+			Definition:  Check{remote: result.remote},
+			State:       CheckState{History: []int{ExitCodeSuccess, ExitCodeWarning}, Occurrences: 1},
+		})
+	}
+
+	// nothing is written until the store is flushed
+	if _, err := os.Stat(path.Join(StateDir, stateStoreFile)); !os.IsNotExist(err) {
+		t.Errorf("expected state not to be written before flush, got %v", err)
+	}
+	store.Prune(map[string]Check{"local": {Command: "check-local.sh"}})
+	if err := store.Flush(); err != nil {
+		t.Fatalf("failed to flush store: %s", err)
+	}
+
+	if store, err = LoadStateStore(); err != nil {
+		t.Fatalf("failed to reload store: %s", err)
+	}
+	checks := store.Checks()
+	if len(checks) != 2 {
+		t.Errorf("expected state of local and remote check only, got %v", checks)
+	}
+	for _, name := range []string{"local", "remote"} {
+		check, ok := checks[name]
+		if !ok || check.Executed != now || check.Status != ExitCodeWarning || check.Occurrences != 1 || len(check.History) != 2 {
+			t.Errorf("unexpected state of %s: %+v", name, check)
+		}
+	}
+}
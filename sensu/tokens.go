@@ -0,0 +1,150 @@
+package sensu
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var tokenRegexp = regexp.MustCompile(`:::(.*?):::`)
+
+// tokenPrefix can be used in tokens to explicitly refer to client attributes
+const tokenPrefix = "client."
+
+// lookupAttribute resolves dot-separated path in client attributes
+func lookupAttribute(attributes map[string]interface{}, attrPath string) (string, bool) {
+	var value interface{} = attributes
+	for _, key := range strings.Split(attrPath, ".") {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if value, ok = nested[key]; !ok {
+			return "", false
+		}
+	}
+	switch typed := value.(type) {
+	case nil, map[string]interface{}:
+		return "", false
+	case []interface{}:
+		items := make([]string, 0, len(typed))
+		for _, item := range typed {
+			items = append(items, fmt.Sprintf("%v", item))
+		}
+		return strings.Join(items, ","), true
+	}
+	return fmt.Sprintf("%v", value), true
+}
+
+//SubstituteTokens replaces Sensu tokens in format ":::attribute.path|default:::" in given input with values
+//of client attributes. Attribute path can be prefixed with "client.". Default value is used when the attribute
+//is not defined. Returns the substituted input and list of tokens which could not be substituted.
+func SubstituteTokens(input string, attributes map[string]interface{}) (string, []string) {
+	unmatched := []string{}
+	output := tokenRegexp.ReplaceAllStringFunc(input, func(token string) string {
+		parts := strings.SplitN(tokenRegexp.FindStringSubmatch(token)[1], "|", 2)
+		attrPath := strings.TrimSpace(parts[0])
+		if value, ok := lookupAttribute(attributes, attrPath); ok {
+			return value
+		}
+		if strings.HasPrefix(attrPath, tokenPrefix) {
+			if value, ok := lookupAttribute(attributes, strings.TrimPrefix(attrPath, tokenPrefix)); ok {
+				return value
+			}
+		}
+		if len(parts) == 2 {
+			return parts[1]
+		}
+		unmatched = append(unmatched, attrPath)
+		return token
+	})
+	return output, unmatched
+}
+
+// substituteValue substitutes tokens in all strings of given decoded JSON value. String consisting
+// of single token is converted to number or boolean if the substituted value is one.
+func substituteValue(value interface{}, attributes map[string]interface{}) (interface{}, []string) {
+	unmatched := []string{}
+	switch typed := value.(type) {
+	case string:
+		output, missing := SubstituteTokens(typed, attributes)
+		if loc := tokenRegexp.FindStringIndex(typed); len(missing) == 0 && loc != nil && loc[0] == 0 && loc[1] == len(typed) {
+			var converted interface{}
+			if err := json.Unmarshal([]byte(output), &converted); err == nil {
+				switch converted.(type) {
+				case float64, bool:
+					return converted, missing
+				}
+			}
+		}
+		return output, missing
+	case []interface{}:
+		for idx, item := range typed {
+			var missing []string
+			typed[idx], missing = substituteValue(item, attributes)
+			unmatched = append(unmatched, missing...)
+		}
+	case map[string]interface{}:
+		for key, item := range typed {
+			var missing []string
+			typed[key], missing = substituteValue(item, attributes)
+			unmatched = append(unmatched, missing...)
+		}
+	}
+	return value, unmatched
+}
+
+// substituteCheck substitutes tokens in command, its arguments, environment, working directory, hooks
+// and native check parameters of given check.
+// Returns error listing all tokens which could not be substituted.
+func (self *Executor) substituteCheck(check Check) (Check, error) {
+	unmatched := []string{}
+	var missing []string
+	check.Command, missing = SubstituteTokens(check.Command, self.ClientAttributes)
+	unmatched = append(unmatched, missing...)
+	check.Cwd, missing = SubstituteTokens(check.Cwd, self.ClientAttributes)
+	unmatched = append(unmatched, missing...)
+	if len(check.Argv) > 0 {
+		argv := make([]string, len(check.Argv))
+		for idx, arg := range check.Argv {
+			argv[idx], missing = SubstituteTokens(arg, self.ClientAttributes)
+			unmatched = append(unmatched, missing...)
+		}
+		check.Argv = argv
+	}
+	if len(check.Env) > 0 {
+		env := make(map[string]string, len(check.Env))
+		for key, value := range check.Env {
+			env[key], missing = SubstituteTokens(value, self.ClientAttributes)
+			unmatched = append(unmatched, missing...)
+		}
+		check.Env = env
+	}
+	if len(check.Hooks) > 0 {
+		hooks := make(map[string]Hook, len(check.Hooks))
+		for key, hook := range check.Hooks {
+			hook.Command, missing = SubstituteTokens(hook.Command, self.ClientAttributes)
+			unmatched = append(unmatched, missing...)
+			hooks[key] = hook
+		}
+		check.Hooks = hooks
+	}
+	if len(check.Params) > 0 {
+		var params interface{}
+		if err := json.Unmarshal(check.Params, &params); err != nil {
+			return check, fmt.Errorf("invalid parameters: %s", err)
+		}
+		params, missing = substituteValue(params, self.ClientAttributes)
+		unmatched = append(unmatched, missing...)
+		substituted, err := json.Marshal(params)
+		if err != nil {
+			return check, fmt.Errorf("invalid parameters: %s", err)
+		}
+		check.Params = substituted
+	}
+	if len(unmatched) > 0 {
+		return check, fmt.Errorf("unmatched command tokens: %s", strings.Join(unmatched, ", "))
+	}
+	return check, nil
+}
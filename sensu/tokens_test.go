@@ -0,0 +1,91 @@
+package sensu
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	connector "github.com/infrawatch/apputils/connector/sensu"
+)
+
+func TestSubstituteTokens(t *testing.T) {
+	attributes := map[string]interface{}{
+		"name":    "node-0",
+		"port":    float64(8080),
+		"enabled": true,
+		"roles":   []interface{}{"db", "web"},
+		"disk":    map[string]interface{}{"warning": "80", "mount": map[string]interface{}{"root": "/"}},
+		"client":  map[string]interface{}{"name": "nested"},
+		"none":    nil,
+	}
+	tests := []struct {
+		input     string
+		output    string
+		unmatched []string
+	}{
+		{"check-http -H :::name::: -p :::port:::", "check-http -H node-0 -p 8080", []string{}},
+		{"check-disk -w :::disk.warning::: -p ::: disk.mount.root :::", "check-disk -w 80 -p /", []string{}},
+		{"check -e :::enabled::: -r :::roles:::", "check -e true -r db,web", []string{}},
+		{"check :::client.name::: :::client.port:::", "check nested 8080", []string{}},
+		{"check -c :::disk.critical|90::: -x :::missing|:::", "check -c 90 -x ", []string{}},
+		{"check :::missing::: :::disk::: :::none::: :::name.first:::", "check :::missing::: :::disk::: :::none::: :::name.first:::", []string{"missing", "disk", "none", "name.first"}},
+		{"no tokens: here", "no tokens: here", []string{}},
+	}
+	for _, test := range tests {
+		output, unmatched := SubstituteTokens(test.input, attributes)
+		if output != test.output {
+			t.Errorf("%s: expected %q, got %q", test.input, test.output, output)
+		}
+		if !reflect.DeepEqual(unmatched, test.unmatched) {
+			t.Errorf("%s: expected unmatched tokens %v, got %v", test.input, test.unmatched, unmatched)
+		}
+	}
+}
+
+func TestSubstituteCheck(t *testing.T) {
+	executor := Executor{ClientAttributes: map[string]interface{}{"name": "node-0", "port": float64(443), "tls": true}}
+	check, err := executor.substituteCheck(Check{
+		Command: "check-http -H :::name:::",
+		Argv:    []string{"check-http", ":::name:::"},
+		Cwd:     "/var/lib/:::name:::",
+		Env:     map[string]string{"HOST": ":::name:::"},
+		Hooks:   map[string]Hook{HookCritical: {Command: "ping :::name:::"}},
+		Params:  json.RawMessage(`{"host": ":::name:::", "ports": [":::port:::", 80, ":::missing|8080:::", "port :::port:::"], "tls": ":::tls:::", "id": ":::name|1:::"}`),
+	})
+	if err != nil {
+		t.Fatalf("failed to substitute tokens: %s", err)
+	}
+	if check.Command != "check-http -H node-0" || check.Argv[1] != "node-0" || check.Cwd != "/var/lib/node-0" ||
+		check.Env["HOST"] != "node-0" || check.Hooks[HookCritical].Command != "ping node-0" ||
+		string(check.Params) != `{"host":"node-0","id":"node-0","ports":[443,80,8080,"port 443"],"tls":true}` {
+		t.Errorf("unexpected substituted check: %+v", check)
+	}
+
+	if _, err := executor.substituteCheck(Check{Command: "check :::missing:::", Env: map[string]string{"X": ":::other:::"}}); err == nil {
+		t.Errorf("expected unmatched tokens to be reported")
+	}
+}
+
+func TestSubstituteNativeParams(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	defer listener.Close()
+	executor := newTestExecutor(t, map[string]Check{
+		"check-port": {Type: CheckTypeTCP, Params: json.RawMessage(`{"host": ":::address:::", "port": ":::api.port:::", "warning": ":::api.warning|5:::"}`)},
+	})
+	executor.ClientAttributes = map[string]interface{}{
+		"address": "127.0.0.1",
+		"api":     map[string]interface{}{"port": float64(listener.Addr().(*net.TCPAddr).Port)},
+	}
+	result, err := executor.Execute(Request{CheckRequest: connector.CheckRequest{Name: "check-port"}})
+	if err != nil {
+		t.Fatalf("failed to execute check: %s", err)
+	}
+	if result.Result.Status != ExitCodeSuccess || !strings.Contains(result.Result.Output, "TCP OK") || !strings.Contains(result.Result.Output, ";5;") {
+		t.Errorf("expected tcp check with substituted port to succeed, got %d: %s", result.Result.Status, result.Result.Output)
+	}
+}